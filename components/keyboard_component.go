@@ -0,0 +1,134 @@
+package components
+
+import (
+	"handheldui/helpers/sdlutils"
+	"handheldui/vars"
+	"strings"
+
+	"github.com/veandco/go-sdl2/sdl"
+)
+
+// Special keys of the on-screen keyboard
+const (
+	KeySpace  = "SPACE"
+	KeyDelete = "DEL"
+	KeyClear  = "CLEAR"
+	KeyShift  = "SHIFT"
+)
+
+var keyboardRows = [][]string{
+	{"1", "2", "3", "4", "5", "6", "7", "8", "9", "0"},
+	{"q", "w", "e", "r", "t", "y", "u", "i", "o", "p"},
+	{"a", "s", "d", "f", "g", "h", "j", "k", "l", "-"},
+	{"z", "x", "c", "v", "b", "n", "m", ".", "(", ")"},
+	{"/", "_", KeyShift, KeySpace, KeyDelete, KeyClear},
+}
+
+type KeyboardComponent struct {
+	renderer *sdl.Renderer
+	text     string
+	row, col int
+	shift    bool
+	x, y     int32
+}
+
+func NewKeyboardComponent(renderer *sdl.Renderer, x, y int32) *KeyboardComponent {
+	return &KeyboardComponent{
+		renderer: renderer,
+		x:        x,
+		y:        y,
+	}
+}
+
+func (k *KeyboardComponent) GetText() string {
+	return k.text
+}
+
+func (k *KeyboardComponent) SetText(text string) {
+	k.text = text
+}
+
+func (k *KeyboardComponent) MoveUp() {
+	if k.row > 0 {
+		k.row--
+		k.clampColumn()
+	}
+}
+
+func (k *KeyboardComponent) MoveDown() {
+	if k.row < len(keyboardRows)-1 {
+		k.row++
+		k.clampColumn()
+	}
+}
+
+func (k *KeyboardComponent) MoveLeft() {
+	if k.col > 0 {
+		k.col--
+	}
+}
+
+func (k *KeyboardComponent) MoveRight() {
+	if k.col < len(keyboardRows[k.row])-1 {
+		k.col++
+	}
+}
+
+// clampColumn keeps the selection inside shorter rows
+func (k *KeyboardComponent) clampColumn() {
+	if k.col >= len(keyboardRows[k.row]) {
+		k.col = len(keyboardRows[k.row]) - 1
+	}
+}
+
+// Press types the selected key
+func (k *KeyboardComponent) Press() {
+	switch key := keyboardRows[k.row][k.col]; key {
+	case KeySpace:
+		k.text += " "
+	case KeyDelete:
+		k.Delete()
+	case KeyClear:
+		k.text = ""
+	case KeyShift:
+		k.shift = !k.shift
+	default:
+		k.text += k.label(key)
+	}
+}
+
+// label returns how a key types, upper case while shift is on
+func (k *KeyboardComponent) label(key string) string {
+	if k.shift && len(key) == 1 {
+		return strings.ToUpper(key)
+	}
+	return key
+}
+
+// Delete removes the last typed character
+func (k *KeyboardComponent) Delete() {
+	if len(k.text) > 0 {
+		k.text = k.text[:len(k.text)-1]
+	}
+}
+
+func (k *KeyboardComponent) Draw(primaryColor sdl.Color, selectedColor sdl.Color) {
+	for rowIndex, row := range keyboardRows {
+		x := k.x
+		for colIndex, key := range row {
+			color := primaryColor
+			if rowIndex == k.row && colIndex == k.col {
+				color = selectedColor
+			}
+
+			sdlutils.DrawText(k.renderer, k.label(key), sdl.Point{X: x, Y: k.y + 48*int32(rowIndex)}, color, vars.BodyFont)
+
+			// Special keys are wider than the letters
+			if len(key) > 1 {
+				x += 160
+			} else {
+				x += 60
+			}
+		}
+	}
+}
@@ -8,9 +8,18 @@ import (
 	"github.com/veandco/go-sdl2/sdl"
 )
 
+// ListSource provides the items of a list too big to keep in memory, page by page
+type ListSource interface {
+	Len() int
+	Page(start, count int) []map[string]interface{}
+}
+
 type ListComponent struct {
 	renderer        *sdl.Renderer
 	items           []map[string]interface{}
+	source          ListSource
+	page            []map[string]interface{}
+	pageStart       int
 	selectedIndex   int
 	scrollOffset    int
 	itemFormatter   func(index int, item map[string]interface{}) string
@@ -20,13 +29,13 @@ type ListComponent struct {
 
 func NewListComponent(renderer *sdl.Renderer, maxVisibleItems, maxItemWidth int, itemFormatter func(index int, item map[string]interface{}) string) *ListComponent {
 	itemsInList := maxVisibleItems
-	if maxVisibleItems > vars.Config.Screen.MaxListItens {
-		itemsInList = vars.Config.Screen.MaxListItens
+	if maxVisibleItems > vars.Config().Screen.MaxListItens {
+		itemsInList = vars.Config().Screen.MaxListItens
 	}
 
 	itemWidth := maxItemWidth
-	if maxItemWidth > vars.Config.Screen.MaxListItemWidth {
-		itemWidth = vars.Config.Screen.MaxListItemWidth
+	if maxItemWidth > vars.Config().Screen.MaxListItemWidth {
+		itemWidth = vars.Config().Screen.MaxListItemWidth
 	}
 
 	return &ListComponent{
@@ -40,12 +49,49 @@ func NewListComponent(renderer *sdl.Renderer, maxVisibleItems, maxItemWidth int,
 
 func (l *ListComponent) SetItems(items []map[string]interface{}) {
 	l.items = items
+	l.source = nil
+	l.page = nil
+	l.selectedIndex = 0
+	l.scrollOffset = 0
+}
+
+// SetSource lists the items of source, reading only the visible ones
+func (l *ListComponent) SetSource(source ListSource) {
+	l.items = nil
+	l.source = source
+	l.page = nil
 	l.selectedIndex = 0
 	l.scrollOffset = 0
 }
 
+// Len returns how many items the list has
+func (l *ListComponent) Len() int {
+	if l.source != nil {
+		return l.source.Len()
+	}
+	return len(l.items)
+}
+
+// visibleItems returns the items from the scroll offset, reading a new page from the
+// source only when the list scrolled
+func (l *ListComponent) visibleItems() []map[string]interface{} {
+	if l.source == nil {
+		endIndex := l.scrollOffset + l.maxVisibleItems
+		if endIndex > len(l.items) {
+			endIndex = len(l.items)
+		}
+		return l.items[l.scrollOffset:endIndex]
+	}
+
+	if l.page == nil || l.pageStart != l.scrollOffset {
+		l.page = l.source.Page(l.scrollOffset, l.maxVisibleItems)
+		l.pageStart = l.scrollOffset
+	}
+	return l.page
+}
+
 func (l *ListComponent) ScrollDown() {
-	if l.selectedIndex < len(l.items)-1 {
+	if l.selectedIndex < l.Len()-1 {
 		l.selectedIndex++
 		if l.selectedIndex >= l.scrollOffset+l.maxVisibleItems {
 			l.scrollOffset++
@@ -63,14 +109,14 @@ func (l *ListComponent) ScrollUp() {
 }
 
 func (l *ListComponent) PageDown() {
-	if l.selectedIndex < len(l.items)-1 {
+	if l.selectedIndex < l.Len()-1 {
 		l.selectedIndex += l.maxVisibleItems
-		if l.selectedIndex >= len(l.items) {
-			l.selectedIndex = len(l.items) - 1
+		if l.selectedIndex >= l.Len() {
+			l.selectedIndex = l.Len() - 1
 		}
 		l.scrollOffset = l.selectedIndex - (l.selectedIndex % l.maxVisibleItems)
-		if l.scrollOffset+l.maxVisibleItems > len(l.items) {
-			l.scrollOffset = len(l.items) - l.maxVisibleItems
+		if l.scrollOffset+l.maxVisibleItems > l.Len() {
+			l.scrollOffset = l.Len() - l.maxVisibleItems
 			if l.scrollOffset < 0 {
 				l.scrollOffset = 0
 			}
@@ -94,11 +140,7 @@ func (l *ListComponent) PageUp() {
 func (l *ListComponent) Draw(primaryColor sdl.Color, selectedColor sdl.Color) {
 	// Draw the items
 	startIndex := l.scrollOffset
-	endIndex := startIndex + l.maxVisibleItems
-	if endIndex > len(l.items) {
-		endIndex = len(l.items)
-	}
-	visibleItems := l.items[startIndex:endIndex]
+	visibleItems := l.visibleItems()
 
 	for index, item := range visibleItems {
 		color := primaryColor
@@ -141,3 +183,19 @@ func (l *ListComponent) GetScrollOffset() int {
 func (l *ListComponent) GetItems() []map[string]interface{} {
 	return l.items
 }
+
+// GetSelectedItem returns the selected item, nil when the list is empty
+func (l *ListComponent) GetSelectedItem() map[string]interface{} {
+	if l.selectedIndex >= l.Len() {
+		return nil
+	}
+	if l.source == nil {
+		return l.items[l.selectedIndex]
+	}
+
+	visible := l.visibleItems()
+	if index := l.selectedIndex - l.pageStart; index >= 0 && index < len(visible) {
+		return visible[index]
+	}
+	return nil
+}
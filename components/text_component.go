@@ -22,7 +22,7 @@ type TextComponent struct {
 
 func NewTextComponent(renderer *sdl.Renderer, text string, font *ttf.Font, maxVisibleLines int, maxWidth int) *TextComponent {
 	visibleLines := maxVisibleLines
-	if maxVisibleLines > vars.Config.Screen.MaxLines {
+	if maxVisibleLines > vars.Config().Screen.MaxLines {
 		visibleLines = maxVisibleLines
 	}
 
@@ -0,0 +1,87 @@
+package image
+
+import (
+	"fmt"
+	goimage "image"
+	"image/jpeg"
+	"image/png"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/image/bmp"
+	"golang.org/x/image/draw"
+)
+
+// ExportImage converts a cached image to the format given by the destination extension
+// (.png, .jpg or .bmp), scaling it to fit inside width x height. A zero width or height
+// keeps the aspect ratio from the other side, and both zero keeps the original size.
+func ExportImage(srcPath, destPath string, width, height int) error {
+	srcFile, err := os.Open(srcPath)
+	if err != nil {
+		return fmt.Errorf("error opening image: %v", err)
+	}
+	defer srcFile.Close()
+
+	img, _, err := goimage.Decode(srcFile)
+	if err != nil {
+		return fmt.Errorf("error decoding image: %v", err)
+	}
+
+	img = scaleToFit(img, width, height)
+
+	if err := os.MkdirAll(filepath.Dir(destPath), 0755); err != nil {
+		return fmt.Errorf("error creating directory: %v", err)
+	}
+
+	// Writes to a temporary file first, so the frontend never sees a partial image
+	tempPath := destPath + ".tmp"
+	out, err := os.Create(tempPath)
+	if err != nil {
+		return fmt.Errorf("error creating image: %v", err)
+	}
+
+	switch strings.ToLower(filepath.Ext(destPath)) {
+	case ".jpg", ".jpeg":
+		err = jpeg.Encode(out, img, &jpeg.Options{Quality: 90})
+	case ".bmp":
+		err = bmp.Encode(out, img)
+	default:
+		err = png.Encode(out, img)
+	}
+	out.Close()
+
+	if err != nil {
+		os.Remove(tempPath)
+		return fmt.Errorf("error encoding image: %v", err)
+	}
+
+	return os.Rename(tempPath, destPath)
+}
+
+func scaleToFit(img goimage.Image, width, height int) goimage.Image {
+	bounds := img.Bounds()
+	srcWidth, srcHeight := bounds.Dx(), bounds.Dy()
+
+	if (width == 0 && height == 0) || srcWidth == 0 || srcHeight == 0 {
+		return img
+	}
+
+	// Keeps the aspect ratio inside the requested box
+	scaledWidth, scaledHeight := width, height
+	switch {
+	case width == 0:
+		scaledWidth = srcWidth * height / srcHeight
+	case height == 0:
+		scaledHeight = srcHeight * width / srcWidth
+	case srcWidth*height > srcHeight*width:
+		scaledHeight = srcHeight * width / srcWidth
+	default:
+		scaledWidth = srcWidth * height / srcHeight
+	}
+
+	scaled := goimage.NewRGBA(goimage.Rect(0, 0, scaledWidth, scaledHeight))
+	draw.CatmullRom.Scale(scaled, scaled.Bounds(), img, bounds, draw.Over, nil)
+
+	return scaled
+}
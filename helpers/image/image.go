@@ -3,7 +3,9 @@ package image
 import (
 	"bytes"
 	"fmt"
+	"handheldui/helpers/network"
 	"handheldui/output"
+	"handheldui/vars"
 	"io"
 	"net/http"
 	"os"
@@ -13,7 +15,7 @@ import (
 )
 
 func FetchGameImage(gameName string, sufix string) string {
-	imagePath := fmt.Sprintf(".cache/images/%s.bmp", gameName)
+	imagePath := fmt.Sprintf(".cache/images/%s.%s.bmp", gameName, sufix)
 
 	// Verificar se o diretório existe, se não, criar
 	dir := fmt.Sprintf(".cache/images")
@@ -30,8 +32,8 @@ func FetchGameImage(gameName string, sufix string) string {
 		return imagePath
 	}
 
-	imageURL := fmt.Sprintf("https://handheld-database.github.io/handheld-database/commons/images/games/%s.%s.webp", gameName, sufix)
-	response, err := http.Get(imageURL)
+	imageURL := fmt.Sprintf("%s/commons/images/games/%s.%s.webp", vars.Config().URLs.Database, gameName, sufix)
+	response, err := network.Get(imageURL)
 	if err != nil {
 		output.Errorf("HTTP request error: %v\n", err)
 		return ""
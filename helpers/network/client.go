@@ -0,0 +1,68 @@
+package network
+
+import (
+	"context"
+	"net/http"
+	"time"
+)
+
+var (
+	client       = &http.Client{}
+	globalBucket *Bucket
+	downloadRate int64
+	hosts        = &hostLimiter{next: make(map[string]time.Time)}
+)
+
+// Configure sets the bandwidth shared by every download, the bandwidth of each download
+// (both in bytes per second) and how many requests per second each host receives.
+// Zero disables a limit.
+func Configure(maxRate, perDownloadRate int64, requestsPerSecond float64) {
+	globalBucket = NewBucket(maxRate)
+	downloadRate = perDownloadRate
+
+	hosts.mu.Lock()
+	defer hosts.mu.Unlock()
+	hosts.interval = 0
+	if requestsPerSecond > 0 {
+		hosts.interval = time.Duration(float64(time.Second) / requestsPerSecond)
+	}
+}
+
+// NewDownloadBucket returns the bucket limiting a single download, nil when unlimited.
+func NewDownloadBucket() *Bucket {
+	return NewBucket(downloadRate)
+}
+
+type requestHookKey struct{}
+
+// WithRequestHook returns a context whose requests sent through Do are passed to hook
+// first, used to attach credentials.
+func WithRequestHook(ctx context.Context, hook func(*http.Request)) context.Context {
+	return context.WithValue(ctx, requestHookKey{}, hook)
+}
+
+// Do sends a request once its host rate limit allows it.
+func Do(req *http.Request) (*http.Response, error) {
+	if hook, ok := req.Context().Value(requestHookKey{}).(func(*http.Request)); ok {
+		hook(req)
+	}
+
+	if err := hosts.wait(req.Context(), req.URL.Host); err != nil {
+		return nil, err
+	}
+	return client.Do(req)
+}
+
+// Get sends a GET request through Do.
+func Get(url string) (*http.Response, error) {
+	return GetWithContext(context.Background(), url)
+}
+
+// GetWithContext sends a GET request through Do, cancelled with ctx.
+func GetWithContext(ctx context.Context, url string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	return Do(req)
+}
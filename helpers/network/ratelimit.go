@@ -0,0 +1,137 @@
+package network
+
+import (
+	"context"
+	"io"
+	"sync"
+	"time"
+)
+
+// Bucket is a token bucket limiting how many bytes per second go through it.
+// A nil bucket does not limit anything.
+type Bucket struct {
+	mu     sync.Mutex
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+// NewBucket returns a bucket for the given bytes per second, or nil when rate is not positive.
+func NewBucket(rate int64) *Bucket {
+	if rate <= 0 {
+		return nil
+	}
+
+	return &Bucket{
+		rate:   float64(rate),
+		burst:  float64(rate),
+		tokens: float64(rate),
+		last:   time.Now(),
+	}
+}
+
+// Wait takes n tokens from the bucket, sleeping until they are available.
+func (b *Bucket) Wait(ctx context.Context, n int) error {
+	if b == nil || n <= 0 {
+		return nil
+	}
+
+	b.mu.Lock()
+	now := time.Now()
+	b.tokens += now.Sub(b.last).Seconds() * b.rate
+	if b.tokens > b.burst {
+		b.tokens = b.burst
+	}
+	b.last = now
+
+	// Takes the tokens right away, so the next caller waits for this one too
+	b.tokens -= float64(n)
+	deficit := -b.tokens
+	b.mu.Unlock()
+
+	if deficit <= 0 {
+		return nil
+	}
+
+	timer := time.NewTimer(time.Duration(deficit / b.rate * float64(time.Second)))
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// limitedReader reads through a set of buckets
+type limitedReader struct {
+	ctx     context.Context
+	reader  io.Reader
+	buckets []*Bucket
+}
+
+// maxRead keeps each read small, so the limited speed stays smooth
+const maxRead = 16 * 1024
+
+// LimitReader returns a reader that waits on every bucket for the bytes it reads.
+// The global bucket is always included.
+func LimitReader(ctx context.Context, reader io.Reader, buckets ...*Bucket) io.Reader {
+	buckets = append(buckets, globalBucket)
+	return &limitedReader{ctx: ctx, reader: reader, buckets: buckets}
+}
+
+func (l *limitedReader) Read(p []byte) (int, error) {
+	if len(p) > maxRead {
+		p = p[:maxRead]
+	}
+
+	n, err := l.reader.Read(p)
+	for _, bucket := range l.buckets {
+		if waitErr := bucket.Wait(l.ctx, n); waitErr != nil {
+			return n, waitErr
+		}
+	}
+
+	return n, err
+}
+
+// hostLimiter spaces the requests sent to the same host
+type hostLimiter struct {
+	mu       sync.Mutex
+	interval time.Duration
+	next     map[string]time.Time
+}
+
+// wait blocks until the host can receive another request
+func (h *hostLimiter) wait(ctx context.Context, host string) error {
+	h.mu.Lock()
+	if h.interval <= 0 {
+		h.mu.Unlock()
+		return nil
+	}
+
+	now := time.Now()
+	at := h.next[host]
+	if at.Before(now) {
+		at = now
+	}
+	h.next[host] = at.Add(h.interval)
+	h.mu.Unlock()
+
+	delay := time.Until(at)
+	if delay <= 0 {
+		return nil
+	}
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
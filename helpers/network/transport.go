@@ -0,0 +1,158 @@
+package network
+
+import (
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+)
+
+// TransportSettings describes how requests reach the servers
+type TransportSettings struct {
+	// Proxy is an http, https or socks5 URL, empty to use the environment proxy
+	Proxy string
+	// Bundles are PEM encoded certificates trusted besides the system roots
+	Bundles [][]byte
+	// BundleFiles are PEM files trusted besides the system roots
+	BundleFiles []string
+	// Pins maps a host, and its subdomains, to the base64 SHA-256 hashes of the public
+	// keys one of its certificates must have
+	Pins map[string][]string
+}
+
+// proxyConfigured is set when the transport was given a proxy
+var proxyConfigured bool
+
+// UsesProxy checks if requests go through a proxy, the configured one or the one of the
+// environment. Connections opened outside of Do, like the torrent peers, would bypass it.
+func UsesProxy() bool {
+	if proxyConfigured {
+		return true
+	}
+	for _, name := range []string{"HTTPS_PROXY", "https_proxy", "HTTP_PROXY", "http_proxy"} {
+		if os.Getenv(name) != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// ConfigureTransport replaces the transport of every request sent through Do.
+func ConfigureTransport(settings TransportSettings) error {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+
+	if settings.Proxy != "" {
+		proxyURL, err := url.Parse(settings.Proxy)
+		if err != nil {
+			return fmt.Errorf("invalid proxy %s: %w", settings.Proxy, err)
+		}
+		switch proxyURL.Scheme {
+		case "http", "https", "socks5", "socks5h":
+		default:
+			return fmt.Errorf("unsupported proxy scheme %q", proxyURL.Scheme)
+		}
+		transport.Proxy = http.ProxyURL(proxyURL)
+	}
+
+	roots, err := rootPool(settings.Bundles, settings.BundleFiles)
+	if err != nil {
+		return err
+	}
+
+	pins, err := parsePins(settings.Pins)
+	if err != nil {
+		return err
+	}
+
+	transport.TLSClientConfig = &tls.Config{
+		RootCAs: roots,
+		VerifyConnection: func(state tls.ConnectionState) error {
+			return verifyPins(state, pins)
+		},
+	}
+
+	client = &http.Client{Transport: transport}
+	proxyConfigured = settings.Proxy != ""
+	return nil
+}
+
+// rootPool adds the bundles to the system roots, which are often missing on handhelds
+func rootPool(bundles [][]byte, files []string) (*x509.CertPool, error) {
+	pool, err := x509.SystemCertPool()
+	if err != nil || pool == nil {
+		pool = x509.NewCertPool()
+	}
+
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return nil, fmt.Errorf("error reading CA bundle %s: %w", file, err)
+		}
+		if !pool.AppendCertsFromPEM(data) {
+			return nil, fmt.Errorf("no certificates found in CA bundle %s", file)
+		}
+	}
+
+	for _, bundle := range bundles {
+		pool.AppendCertsFromPEM(bundle)
+	}
+
+	return pool, nil
+}
+
+// parsePins decodes the pinned hashes of each host
+func parsePins(pins map[string][]string) (map[string][][]byte, error) {
+	parsed := make(map[string][][]byte)
+	for host, hashes := range pins {
+		for _, hash := range hashes {
+			sum, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(hash, "sha256/"))
+			if err != nil || len(sum) != sha256.Size {
+				return nil, fmt.Errorf("invalid pin %q for %s", hash, host)
+			}
+			parsed[strings.ToLower(host)] = append(parsed[strings.ToLower(host)], sum)
+		}
+	}
+	return parsed, nil
+}
+
+// verifyPins checks that a verified chain of a pinned host contains a pinned public key
+func verifyPins(state tls.ConnectionState, pins map[string][][]byte) error {
+	hashes := pinsForHost(state.ServerName, pins)
+	if hashes == nil {
+		return nil
+	}
+
+	for _, chain := range state.VerifiedChains {
+		for _, cert := range chain {
+			sum := sha256.Sum256(cert.RawSubjectPublicKeyInfo)
+			for _, hash := range hashes {
+				if string(hash) == string(sum[:]) {
+					return nil
+				}
+			}
+		}
+	}
+
+	return fmt.Errorf("certificate of %s does not match its pins", state.ServerName)
+}
+
+// pinsForHost returns the pins of host or of the closest pinned parent domain
+func pinsForHost(host string, pins map[string][][]byte) [][]byte {
+	host = strings.ToLower(host)
+	for host != "" {
+		if hashes, ok := pins[host]; ok {
+			return hashes
+		}
+		_, parent, found := strings.Cut(host, ".")
+		if !found {
+			break
+		}
+		host = parent
+	}
+	return nil
+}
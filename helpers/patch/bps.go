@@ -0,0 +1,124 @@
+package patch
+
+import (
+	"bytes"
+	"fmt"
+	"hash/crc32"
+)
+
+// BPS actions
+const (
+	bpsSourceRead = iota
+	bpsTargetRead
+	bpsSourceCopy
+	bpsTargetCopy
+)
+
+// ApplyBPS applies a BPS patch, checking the source and target checksums.
+func ApplyBPS(source, patchData []byte) ([]byte, error) {
+	if !bytes.HasPrefix(patchData, []byte("BPS1")) {
+		return nil, fmt.Errorf("invalid BPS header")
+	}
+
+	sourceCRC, targetCRC, err := checkFooter(patchData)
+	if err != nil {
+		return nil, err
+	}
+
+	if crc32.ChecksumIEEE(source) != sourceCRC {
+		return nil, ErrSourceMismatch
+	}
+
+	r := &reader{data: patchData, offset: 4, end: len(patchData) - 12}
+
+	sourceSize, err := r.readNumber()
+	if err != nil {
+		return nil, err
+	}
+	if sourceSize != uint64(len(source)) {
+		return nil, ErrSourceMismatch
+	}
+
+	targetSize, err := r.readNumber()
+	if err != nil {
+		return nil, err
+	}
+
+	// The metadata is not needed to apply the patch
+	metadataSize, err := r.readNumber()
+	if err != nil {
+		return nil, err
+	}
+	r.offset += int(metadataSize)
+
+	target := make([]byte, targetSize)
+	var outputOffset, sourceOffset, targetOffset int64
+
+	for r.offset < r.end {
+		data, err := r.readNumber()
+		if err != nil {
+			return nil, err
+		}
+		action := data & 3
+		length := int64(data>>2) + 1
+
+		if outputOffset+length > int64(targetSize) {
+			return nil, fmt.Errorf("patch writes past the end of the target")
+		}
+
+		switch action {
+		case bpsSourceRead:
+			if outputOffset+length > int64(len(source)) {
+				return nil, fmt.Errorf("patch reads past the end of the source")
+			}
+			copy(target[outputOffset:outputOffset+length], source[outputOffset:outputOffset+length])
+			outputOffset += length
+
+		case bpsTargetRead:
+			if r.offset+int(length) > r.end {
+				return nil, fmt.Errorf("unexpected end of patch")
+			}
+			copy(target[outputOffset:outputOffset+length], patchData[r.offset:r.offset+int(length)])
+			r.offset += int(length)
+			outputOffset += length
+
+		case bpsSourceCopy, bpsTargetCopy:
+			data, err := r.readNumber()
+			if err != nil {
+				return nil, err
+			}
+			relative := int64(data >> 1)
+			if data&1 != 0 {
+				relative = -relative
+			}
+
+			if action == bpsSourceCopy {
+				sourceOffset += relative
+				if sourceOffset < 0 || sourceOffset+length > int64(len(source)) {
+					return nil, fmt.Errorf("patch reads past the end of the source")
+				}
+				copy(target[outputOffset:outputOffset+length], source[sourceOffset:sourceOffset+length])
+				sourceOffset += length
+				outputOffset += length
+				continue
+			}
+
+			targetOffset += relative
+			if targetOffset < 0 || targetOffset >= outputOffset {
+				return nil, fmt.Errorf("invalid target copy in patch")
+			}
+			// Target copies may overlap the bytes being written, so copy one at a time
+			for i := int64(0); i < length; i++ {
+				target[outputOffset] = target[targetOffset]
+				outputOffset++
+				targetOffset++
+			}
+		}
+	}
+
+	if crc32.ChecksumIEEE(target) != targetCRC {
+		return nil, fmt.Errorf("patched file checksum mismatch")
+	}
+
+	return target, nil
+}
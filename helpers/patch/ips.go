@@ -0,0 +1,69 @@
+package patch
+
+import (
+	"bytes"
+	"fmt"
+)
+
+// ApplyIPS applies an IPS patch. IPS has no checksums, so the result must be verified
+// by the caller when an expected checksum is known.
+func ApplyIPS(source, patchData []byte) ([]byte, error) {
+	if !bytes.HasPrefix(patchData, []byte("PATCH")) {
+		return nil, fmt.Errorf("invalid IPS header")
+	}
+
+	target := append([]byte{}, source...)
+	offset := 5
+
+	for {
+		if offset+3 > len(patchData) {
+			return nil, fmt.Errorf("unexpected end of IPS patch")
+		}
+
+		if string(patchData[offset:offset+3]) == "EOF" {
+			offset += 3
+			break
+		}
+
+		recordOffset := int(patchData[offset])<<16 | int(patchData[offset+1])<<8 | int(patchData[offset+2])
+		offset += 3
+
+		if offset+2 > len(patchData) {
+			return nil, fmt.Errorf("unexpected end of IPS patch")
+		}
+		size := int(patchData[offset])<<8 | int(patchData[offset+1])
+		offset += 2
+
+		// A record without size is a run of the same byte
+		var data []byte
+		if size == 0 {
+			if offset+3 > len(patchData) {
+				return nil, fmt.Errorf("unexpected end of IPS patch")
+			}
+			runSize := int(patchData[offset])<<8 | int(patchData[offset+1])
+			data = bytes.Repeat([]byte{patchData[offset+2]}, runSize)
+			offset += 3
+		} else {
+			if offset+size > len(patchData) {
+				return nil, fmt.Errorf("unexpected end of IPS patch")
+			}
+			data = patchData[offset : offset+size]
+			offset += size
+		}
+
+		if end := recordOffset + len(data); end > len(target) {
+			target = append(target, make([]byte, end-len(target))...)
+		}
+		copy(target[recordOffset:], data)
+	}
+
+	// Some patches truncate the file after the EOF marker
+	if offset+3 <= len(patchData) {
+		truncate := int(patchData[offset])<<16 | int(patchData[offset+1])<<8 | int(patchData[offset+2])
+		if truncate < len(target) {
+			target = target[:truncate]
+		}
+	}
+
+	return target, nil
+}
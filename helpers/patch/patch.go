@@ -0,0 +1,90 @@
+package patch
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"hash/crc32"
+)
+
+// ErrSourceMismatch is returned when a patch is applied to a file it was not made for
+var ErrSourceMismatch = errors.New("source file does not match the patch")
+
+// IsIPS checks if the patch data is an IPS patch, which can't tell if it is applied to
+// the right file
+func IsIPS(patchData []byte) bool {
+	return bytes.HasPrefix(patchData, []byte("PATCH"))
+}
+
+// Apply detects the format of the patch (IPS, UPS or BPS) and returns the patched data.
+func Apply(source, patchData []byte) ([]byte, error) {
+	switch {
+	case IsIPS(patchData):
+		return ApplyIPS(source, patchData)
+	case bytes.HasPrefix(patchData, []byte("UPS1")):
+		return ApplyUPS(source, patchData)
+	case bytes.HasPrefix(patchData, []byte("BPS1")):
+		return ApplyBPS(source, patchData)
+	default:
+		return nil, fmt.Errorf("unknown patch format")
+	}
+}
+
+// checkFooter validates the CRC32 footer shared by UPS and BPS patches and returns the
+// expected source and target checksums.
+func checkFooter(patchData []byte) (uint32, uint32, error) {
+	if len(patchData) < 12 {
+		return 0, 0, fmt.Errorf("patch is too small")
+	}
+
+	footer := patchData[len(patchData)-12:]
+	sourceCRC := readUint32LE(footer[0:4])
+	targetCRC := readUint32LE(footer[4:8])
+	patchCRC := readUint32LE(footer[8:12])
+
+	if crc32.ChecksumIEEE(patchData[:len(patchData)-4]) != patchCRC {
+		return 0, 0, fmt.Errorf("patch checksum mismatch")
+	}
+
+	return sourceCRC, targetCRC, nil
+}
+
+func readUint32LE(data []byte) uint32 {
+	return uint32(data[0]) | uint32(data[1])<<8 | uint32(data[2])<<16 | uint32(data[3])<<24
+}
+
+// reader reads the variable length numbers used by UPS and BPS patches
+type reader struct {
+	data   []byte
+	offset int
+	end    int
+}
+
+func (r *reader) readByte() (byte, error) {
+	if r.offset >= r.end {
+		return 0, fmt.Errorf("unexpected end of patch")
+	}
+	b := r.data[r.offset]
+	r.offset++
+	return b, nil
+}
+
+func (r *reader) readNumber() (uint64, error) {
+	var value uint64
+	var shift uint64 = 1
+	for {
+		b, err := r.readByte()
+		if err != nil {
+			return 0, err
+		}
+		value += uint64(b&0x7f) * shift
+		if b&0x80 != 0 {
+			return value, nil
+		}
+		shift <<= 7
+		value += shift
+		if shift > 1<<56 {
+			return 0, fmt.Errorf("invalid number in patch")
+		}
+	}
+}
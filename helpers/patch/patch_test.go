@@ -0,0 +1,243 @@
+package patch
+
+import (
+	"bytes"
+	"errors"
+	"hash/crc32"
+	"testing"
+)
+
+// encodeNumber writes a variable length number of UPS and BPS patches
+func encodeNumber(value uint64) []byte {
+	var data []byte
+	for {
+		x := byte(value & 0x7f)
+		value >>= 7
+		if value == 0 {
+			return append(data, 0x80|x)
+		}
+		data = append(data, x)
+		value--
+	}
+}
+
+func uint32LE(value uint32) []byte {
+	return []byte{byte(value), byte(value >> 8), byte(value >> 16), byte(value >> 24)}
+}
+
+// withFooter appends the source, target and patch checksums
+func withFooter(body []byte, source, target []byte) []byte {
+	data := append([]byte{}, body...)
+	data = append(data, uint32LE(crc32.ChecksumIEEE(source))...)
+	data = append(data, uint32LE(crc32.ChecksumIEEE(target))...)
+	return append(data, uint32LE(crc32.ChecksumIEEE(data))...)
+}
+
+// makeUPS builds the UPS patch turning source into target
+func makeUPS(source, target []byte) []byte {
+	byteAt := func(data []byte, i int) byte {
+		if i < len(data) {
+			return data[i]
+		}
+		return 0
+	}
+
+	body := []byte("UPS1")
+	body = append(body, encodeNumber(uint64(len(source)))...)
+	body = append(body, encodeNumber(uint64(len(target)))...)
+
+	size := len(source)
+	if len(target) > size {
+		size = len(target)
+	}
+
+	last := 0
+	for i := 0; i < size; {
+		if byteAt(source, i) == byteAt(target, i) {
+			i++
+			continue
+		}
+		body = append(body, encodeNumber(uint64(i-last))...)
+		for ; i < size && byteAt(source, i) != byteAt(target, i); i++ {
+			body = append(body, byteAt(source, i)^byteAt(target, i))
+		}
+		body = append(body, 0)
+		i++
+		last = i
+	}
+
+	return withFooter(body, source, target)
+}
+
+// ipsRecord builds an IPS record, a run of the same byte when data has a single byte and
+// run is set
+func ipsRecord(offset int, data []byte, run int) []byte {
+	record := []byte{byte(offset >> 16), byte(offset >> 8), byte(offset)}
+	if run > 0 {
+		return append(record, 0, 0, byte(run>>8), byte(run), data[0])
+	}
+	record = append(record, byte(len(data)>>8), byte(len(data)))
+	return append(record, data...)
+}
+
+func makeIPS(records ...[]byte) []byte {
+	data := []byte("PATCH")
+	for _, record := range records {
+		data = append(data, record...)
+	}
+	return append(data, []byte("EOF")...)
+}
+
+// bpsAction encodes a BPS action, with its relative offset for the copy actions
+func bpsAction(action uint64, length int, relative int) []byte {
+	data := encodeNumber(uint64(length-1)<<2 | action)
+	if action == bpsSourceCopy || action == bpsTargetCopy {
+		value := uint64(relative) << 1
+		if relative < 0 {
+			value = uint64(-relative)<<1 | 1
+		}
+		data = append(data, encodeNumber(value)...)
+	}
+	return data
+}
+
+func makeBPS(source, target []byte, actions ...[]byte) []byte {
+	body := []byte("BPS1")
+	body = append(body, encodeNumber(uint64(len(source)))...)
+	body = append(body, encodeNumber(uint64(len(target)))...)
+	body = append(body, encodeNumber(0)...)
+	for _, action := range actions {
+		body = append(body, action...)
+	}
+	return withFooter(body, source, target)
+}
+
+var (
+	bpsSource = []byte("ABCDEFGH")
+	bpsTarget = []byte("ABCDxyEFGHxyEFFFFAB")
+	bpsPatch  = makeBPS(bpsSource, bpsTarget,
+		bpsAction(bpsSourceRead, 4, 0),
+		append(bpsAction(bpsTargetRead, 2, 0), 'x', 'y'),
+		bpsAction(bpsSourceCopy, 4, 4),
+		bpsAction(bpsTargetCopy, 4, 4),
+		// Overlaps the bytes being written, repeating the last one
+		bpsAction(bpsTargetCopy, 3, 5),
+		bpsAction(bpsSourceCopy, 2, -8),
+	)
+)
+
+// corrupt flips a byte of a copy of data
+func corrupt(data []byte, index int) []byte {
+	corrupted := append([]byte{}, data...)
+	corrupted[index] ^= 0xff
+	return corrupted
+}
+
+func TestApply(t *testing.T) {
+	upsSource := []byte("Hello, World!")
+	upsTarget := []byte("Hello, Gophers and World!")
+	upsPatch := makeUPS(upsSource, upsTarget)
+
+	tests := []struct {
+		name   string
+		source []byte
+		patch  []byte
+		want   []byte
+	}{
+		{
+			name:   "ips record",
+			source: []byte("0123456789"),
+			patch:  makeIPS(ipsRecord(2, []byte("ab"), 0)),
+			want:   []byte("01ab456789"),
+		},
+		{
+			name:   "ips rle record",
+			source: []byte("0123456789"),
+			patch:  makeIPS(ipsRecord(3, []byte("z"), 4)),
+			want:   []byte("012zzzz789"),
+		},
+		{
+			name:   "ips record past the end grows the file",
+			source: []byte("0123"),
+			patch:  makeIPS(ipsRecord(6, []byte("xy"), 0)),
+			want:   []byte("0123\x00\x00xy"),
+		},
+		{
+			name:   "ips truncation",
+			source: []byte("0123456789"),
+			patch:  append(makeIPS(ipsRecord(0, []byte("a"), 0)), 0, 0, 6),
+			want:   []byte("a12345"),
+		},
+		{
+			name:   "ups forward",
+			source: upsSource,
+			patch:  upsPatch,
+			want:   upsTarget,
+		},
+		{
+			name:   "ups backward",
+			source: upsTarget,
+			patch:  upsPatch,
+			want:   upsSource,
+		},
+		{
+			name:   "bps source and target copies",
+			source: bpsSource,
+			patch:  bpsPatch,
+			want:   bpsTarget,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := Apply(test.source, test.patch)
+			if err != nil {
+				t.Fatalf("Apply() error = %v", err)
+			}
+			if !bytes.Equal(got, test.want) {
+				t.Errorf("Apply() = %q, want %q", got, test.want)
+			}
+		})
+	}
+}
+
+func TestApplyErrors(t *testing.T) {
+	upsSource := []byte("Hello, World!")
+	upsPatch := makeUPS(upsSource, []byte("Hello, Gophers!"))
+	ipsPatch := makeIPS(ipsRecord(2, []byte("ab"), 0), ipsRecord(3, []byte("z"), 4))
+
+	// The footer of the patch is valid, but the target checksum is not the result's
+	badTarget := makeBPS(bpsSource, []byte("ABCD"), bpsAction(bpsSourceRead, 4, 0))
+	badTarget = withFooter(badTarget[:len(badTarget)-12], bpsSource, []byte("ABCE"))
+
+	tests := []struct {
+		name     string
+		source   []byte
+		patch    []byte
+		mismatch bool
+	}{
+		{name: "unknown format", source: upsSource, patch: []byte("NOTAPATCH")},
+		{name: "ips without eof", source: upsSource, patch: ipsPatch[:len(ipsPatch)-3]},
+		{name: "ips truncated record", source: upsSource, patch: ipsPatch[:9]},
+		{name: "ips truncated rle record", source: upsSource, patch: ipsPatch[:len(ipsPatch)-5]},
+		{name: "ups corrupted", source: upsSource, patch: corrupt(upsPatch, 8)},
+		{name: "ups truncated", source: upsSource, patch: upsPatch[:10]},
+		{name: "ups wrong source", source: []byte("Goodbye"), patch: upsPatch, mismatch: true},
+		{name: "bps corrupted", source: bpsSource, patch: corrupt(bpsPatch, 10)},
+		{name: "bps truncated", source: bpsSource, patch: bpsPatch[:len(bpsPatch)-13]},
+		{name: "bps wrong source", source: []byte("ABCDEFGX"), patch: bpsPatch, mismatch: true},
+		{name: "bps target checksum", source: bpsSource, patch: badTarget},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := Apply(test.source, test.patch)
+			if err == nil {
+				t.Fatalf("Apply() = %q, want an error", got)
+			}
+			if mismatch := errors.Is(err, ErrSourceMismatch); mismatch != test.mismatch {
+				t.Errorf("Apply() error = %v, source mismatch %v, want %v", err, mismatch, test.mismatch)
+			}
+		})
+	}
+}
@@ -0,0 +1,77 @@
+package patch
+
+import (
+	"bytes"
+	"fmt"
+	"hash/crc32"
+)
+
+// ApplyUPS applies an UPS patch, checking the source and target checksums. UPS patches
+// work in both directions, so a patched file can also be reverted.
+func ApplyUPS(source, patchData []byte) ([]byte, error) {
+	if !bytes.HasPrefix(patchData, []byte("UPS1")) {
+		return nil, fmt.Errorf("invalid UPS header")
+	}
+
+	sourceCRC, targetCRC, err := checkFooter(patchData)
+	if err != nil {
+		return nil, err
+	}
+
+	r := &reader{data: patchData, offset: 4, end: len(patchData) - 12}
+
+	sourceSize, err := r.readNumber()
+	if err != nil {
+		return nil, err
+	}
+	targetSize, err := r.readNumber()
+	if err != nil {
+		return nil, err
+	}
+
+	inputCRC := crc32.ChecksumIEEE(source)
+	switch {
+	case inputCRC == sourceCRC && uint64(len(source)) == sourceSize:
+	case inputCRC == targetCRC && uint64(len(source)) == targetSize:
+		sourceSize, targetSize = targetSize, sourceSize
+		sourceCRC, targetCRC = targetCRC, sourceCRC
+	default:
+		return nil, ErrSourceMismatch
+	}
+
+	target := make([]byte, targetSize)
+	copy(target, source)
+
+	var position uint64
+	for r.offset < r.end {
+		relative, err := r.readNumber()
+		if err != nil {
+			return nil, err
+		}
+		position += relative
+
+		for {
+			x, err := r.readByte()
+			if err != nil {
+				return nil, err
+			}
+			if position < targetSize {
+				var sourceByte byte
+				if position < sourceSize {
+					sourceByte = source[position]
+				}
+				target[position] = sourceByte ^ x
+			}
+			position++
+			if x == 0 {
+				break
+			}
+		}
+	}
+
+	if crc32.ChecksumIEEE(target) != targetCRC {
+		return nil, fmt.Errorf("patched file checksum mismatch")
+	}
+
+	return target, nil
+}
@@ -30,6 +30,8 @@ func InitMixer() error {
 		return output.Errorf("failed to open audio: %w", err)
 	}
 	mix.Volume(-1, mix.MAX_VOLUME)
+	// The first channel plays the looping sounds only
+	mix.ReserveChannels(1)
 	return nil
 }
 
@@ -11,7 +11,7 @@ import (
 )
 
 func getImagePath(str string) string {
-	return strings.ReplaceAll(str, "$aspect_ratio", vars.Config.Screen.AspectRatio)
+	return strings.ReplaceAll(str, "$aspect_ratio", vars.Config().Screen.AspectRatio)
 }
 
 // LoadTexture loads an image and creates an SDL texture from it
@@ -87,7 +87,7 @@ func RenderTexture(renderer *sdl.Renderer, imagePath string, destRect sdl.Rect,
 
 func RenderTextureCartesian(renderer *sdl.Renderer, imagePath string, startQuadrant, endQuadrant string) {
 
-	screenWidth, screenHeight := vars.Config.Screen.Width, vars.Config.Screen.Height
+	screenWidth, screenHeight := vars.Config().Screen.Width, vars.Config().Screen.Height
 
 	// Define the quadrants
 	quadrants := map[string]sdl.Rect{
@@ -165,7 +165,7 @@ func RenderScaledTexture(renderer *sdl.Renderer, imgPath string, x, y int32, sca
 
 func RenderTextureCover(renderer *sdl.Renderer, imagePath string) {
 	// Get screen dimensions
-	screenWidth, screenHeight := vars.Config.Screen.Width, vars.Config.Screen.Height
+	screenWidth, screenHeight := vars.Config().Screen.Width, vars.Config().Screen.Height
 
 	// Load and calculate the aspect of the image
 	textureSurface, err := sdl.LoadBMP(imagePath)
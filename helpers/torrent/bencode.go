@@ -0,0 +1,132 @@
+package torrent
+
+import (
+	"fmt"
+	"strconv"
+)
+
+// decoder reads bencoded values. Strings are returned as string, integers as int64,
+// lists as []interface{} and dictionaries as map[string]interface{}.
+type decoder struct {
+	data  []byte
+	pos   int
+	depth int
+	// infoRaw keeps the exact bytes of the top-level info dictionary, hashed for the info hash
+	infoRaw []byte
+}
+
+// Decode parses a bencoded value.
+func Decode(data []byte) (interface{}, error) {
+	d := &decoder{data: data}
+	return d.value()
+}
+
+func (d *decoder) value() (interface{}, error) {
+	if d.pos >= len(d.data) {
+		return nil, fmt.Errorf("unexpected end of data")
+	}
+
+	switch c := d.data[d.pos]; {
+	case c == 'i':
+		return d.integer()
+	case c == 'l':
+		return d.list()
+	case c == 'd':
+		return d.dictionary()
+	case c >= '0' && c <= '9':
+		return d.string()
+	default:
+		return nil, fmt.Errorf("invalid bencode at %d", d.pos)
+	}
+}
+
+func (d *decoder) integer() (int64, error) {
+	end := d.indexFrom('e', d.pos+1)
+	if end < 0 {
+		return 0, fmt.Errorf("unterminated integer at %d", d.pos)
+	}
+
+	value, err := strconv.ParseInt(string(d.data[d.pos+1:end]), 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid integer at %d: %v", d.pos, err)
+	}
+
+	d.pos = end + 1
+	return value, nil
+}
+
+func (d *decoder) string() (string, error) {
+	colon := d.indexFrom(':', d.pos)
+	if colon < 0 {
+		return "", fmt.Errorf("invalid string at %d", d.pos)
+	}
+
+	length, err := strconv.Atoi(string(d.data[d.pos:colon]))
+	if err != nil || length < 0 || colon+1+length > len(d.data) {
+		return "", fmt.Errorf("invalid string length at %d", d.pos)
+	}
+
+	d.pos = colon + 1 + length
+	return string(d.data[colon+1 : d.pos]), nil
+}
+
+func (d *decoder) list() ([]interface{}, error) {
+	d.pos++
+	d.depth++
+	defer func() { d.depth-- }()
+
+	list := []interface{}{}
+	for d.pos < len(d.data) && d.data[d.pos] != 'e' {
+		value, err := d.value()
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, value)
+	}
+
+	if d.pos >= len(d.data) {
+		return nil, fmt.Errorf("unterminated list")
+	}
+	d.pos++
+	return list, nil
+}
+
+func (d *decoder) dictionary() (map[string]interface{}, error) {
+	d.pos++
+	d.depth++
+	defer func() { d.depth-- }()
+
+	dict := make(map[string]interface{})
+	for d.pos < len(d.data) && d.data[d.pos] != 'e' {
+		key, err := d.string()
+		if err != nil {
+			return nil, err
+		}
+
+		start := d.pos
+		value, err := d.value()
+		if err != nil {
+			return nil, err
+		}
+
+		if d.depth == 1 && key == "info" {
+			d.infoRaw = d.data[start:d.pos]
+		}
+		dict[key] = value
+	}
+
+	if d.pos >= len(d.data) {
+		return nil, fmt.Errorf("unterminated dictionary")
+	}
+	d.pos++
+	return dict, nil
+}
+
+func (d *decoder) indexFrom(c byte, from int) int {
+	for i := from; i < len(d.data); i++ {
+		if d.data[i] == c {
+			return i
+		}
+	}
+	return -1
+}
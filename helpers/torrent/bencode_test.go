@@ -0,0 +1,152 @@
+package torrent
+
+import (
+	"crypto/sha1"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// encode bencodes strings, integers, lists and dictionaries, for the test fixtures
+func encode(value interface{}) string {
+	switch v := value.(type) {
+	case string:
+		return fmt.Sprintf("%d:%s", len(v), v)
+	case int:
+		return fmt.Sprintf("i%de", v)
+	case int64:
+		return fmt.Sprintf("i%de", v)
+	case []interface{}:
+		var builder strings.Builder
+		builder.WriteString("l")
+		for _, item := range v {
+			builder.WriteString(encode(item))
+		}
+		return builder.String() + "e"
+	case map[string]interface{}:
+		keys := make([]string, 0, len(v))
+		for key := range v {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+
+		var builder strings.Builder
+		builder.WriteString("d")
+		for _, key := range keys {
+			builder.WriteString(encode(key) + encode(v[key]))
+		}
+		return builder.String() + "e"
+	}
+	panic(fmt.Sprintf("cannot encode %T", value))
+}
+
+// makeTorrent returns a single file torrent of data and its info dictionary
+func makeTorrent(name string, data []byte, pieceLength int, announce string) ([]byte, string) {
+	var pieces strings.Builder
+	for start := 0; start < len(data); start += pieceLength {
+		end := start + pieceLength
+		if end > len(data) {
+			end = len(data)
+		}
+		hash := sha1.Sum(data[start:end])
+		pieces.Write(hash[:])
+	}
+
+	info := encode(map[string]interface{}{
+		"name":         name,
+		"length":       len(data),
+		"piece length": pieceLength,
+		"pieces":       pieces.String(),
+	})
+
+	return []byte("d8:announce" + encode(announce) + "4:info" + info + "e"), info
+}
+
+func TestDecode(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    string
+		want    interface{}
+		wantErr bool
+	}{
+		{name: "string", data: "4:spam", want: "spam"},
+		{name: "empty string", data: "0:", want: ""},
+		{name: "integer", data: "i-42e", want: int64(-42)},
+		{name: "list", data: "l4:spami7ee", want: []interface{}{"spam", int64(7)}},
+		{
+			name: "nested dictionary",
+			data: "d3:bar4:spam3:fooli1ei2eee",
+			want: map[string]interface{}{"bar": "spam", "foo": []interface{}{int64(1), int64(2)}},
+		},
+		{name: "invalid integer", data: "i4x2e", wantErr: true},
+		{name: "unterminated integer", data: "i42", wantErr: true},
+		{name: "string past the end", data: "10:spam", wantErr: true},
+		{name: "unterminated list", data: "l4:spam", wantErr: true},
+		{name: "unterminated dictionary", data: "d3:foo3:bar", wantErr: true},
+		{name: "dictionary key not a string", data: "di1e3:fooe", wantErr: true},
+		{name: "unknown type", data: "x", wantErr: true},
+		{name: "empty", data: "", wantErr: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := Decode([]byte(test.data))
+			if test.wantErr {
+				if err == nil {
+					t.Fatalf("Decode() = %v, want an error", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Decode() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("Decode() = %#v, want %#v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestParseMetaInfo(t *testing.T) {
+	data := []byte("0123456789abcdefghij")
+	torrent, info := makeTorrent("game.bin", data, 8, "http://tracker.example/announce")
+
+	meta, err := ParseMetaInfo(torrent)
+	if err != nil {
+		t.Fatalf("ParseMetaInfo() error = %v", err)
+	}
+
+	if meta.InfoHash != sha1.Sum([]byte(info)) {
+		t.Errorf("InfoHash is not the hash of the info dictionary")
+	}
+	if meta.Name != "game.bin" || meta.PieceLength != 8 || meta.TotalLength != int64(len(data)) {
+		t.Errorf("ParseMetaInfo() = %+v", meta)
+	}
+	if len(meta.Pieces) != 3 || meta.pieceSize(2) != 4 {
+		t.Errorf("got %d pieces, the last of %d bytes, want 3 and 4", len(meta.Pieces), meta.pieceSize(2))
+	}
+	if !reflect.DeepEqual(meta.Announce, []string{"http://tracker.example/announce"}) {
+		t.Errorf("Announce = %v", meta.Announce)
+	}
+
+	invalid := []struct {
+		name string
+		data string
+	}{
+		{name: "not a dictionary", data: "l4:infoe"},
+		{name: "no info", data: "d8:announce3:urle"},
+		{name: "info not a dictionary", data: "d4:info4:spame"},
+		{name: "missing pieces", data: "d4:infod6:lengthi20e4:name1:x12:piece lengthi8eee"},
+		{name: "truncated", data: string(torrent[:len(torrent)-2])},
+	}
+
+	for _, test := range invalid {
+		t.Run(test.name, func(t *testing.T) {
+			if meta, err := ParseMetaInfo([]byte(test.data)); err == nil {
+				t.Errorf("ParseMetaInfo() = %+v, want an error", meta)
+			}
+		})
+	}
+}
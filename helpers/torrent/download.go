@@ -0,0 +1,295 @@
+package torrent
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha1"
+	"fmt"
+	"handheldui/helpers/network"
+	"handheldui/output"
+	"io"
+	"net/http"
+	"os"
+	"sync"
+)
+
+// maxPeers is how many peers are downloaded from at the same time
+const maxPeers = 8
+
+// download keeps the state of a file being downloaded from a torrent
+type download struct {
+	meta       *MetaInfo
+	file       FileEntry
+	out        *os.File
+	bucket     *network.Bucket
+	authorize  func(*http.Request)
+	progress   func(int64, int64)
+	mu         sync.Mutex
+	pending    map[int]bool
+	inProgress map[int]bool
+	written    int64
+}
+
+// Download writes one file of a torrent to destPath. Pieces come from the peers returned
+// by the trackers, and the pieces no peer could send are fetched from webSeed, the HTTP
+// URL of the same file, with range requests. authorize, when set, is only applied to the
+// web seed requests.
+func Download(ctx context.Context, meta *MetaInfo, path, destPath, webSeed string, authorize func(*http.Request), progress func(int64, int64)) error {
+	file, ok := meta.FindFile(path)
+	if !ok {
+		return fmt.Errorf("%s is not part of the torrent", path)
+	}
+
+	out, err := os.Create(destPath)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+
+	if err := out.Truncate(file.Length); err != nil {
+		return err
+	}
+
+	d := &download{
+		meta:       meta,
+		file:       file,
+		out:        out,
+		bucket:     network.NewDownloadBucket(),
+		authorize:  authorize,
+		progress:   progress,
+		pending:    make(map[int]bool),
+		inProgress: make(map[int]bool),
+	}
+
+	if file.Length == 0 {
+		return nil
+	}
+
+	// Only the pieces holding bytes of the file are needed
+	first := int(file.Offset / meta.PieceLength)
+	last := int((file.Offset + file.Length - 1) / meta.PieceLength)
+	for index := first; index <= last; index++ {
+		d.pending[index] = true
+	}
+
+	d.downloadFromPeers(ctx)
+
+	if ctx.Err() != nil {
+		return fmt.Errorf("download cancelled")
+	}
+
+	if remaining := d.remaining(); len(remaining) > 0 {
+		if webSeed == "" {
+			return fmt.Errorf("%d pieces not available from any peer", len(remaining))
+		}
+
+		output.Printf("Downloading %d pieces of %s from the web seed\n", len(remaining), path)
+		for _, index := range remaining {
+			if err := d.downloadFromWebSeed(ctx, webSeed, index); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// downloadFromPeers asks the trackers for peers and downloads from them until no peer
+// has any of the missing pieces
+func (d *download) downloadFromPeers(ctx context.Context) {
+	// Stops the peers still connected once the pieces are downloaded
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var peerID [20]byte
+	copy(peerID[:], "-HU0001-")
+	rand.Read(peerID[8:])
+
+	seen := make(map[string]bool)
+	var peers []string
+	for _, tracker := range d.meta.Announce {
+		found, err := announce(ctx, tracker, d.meta, peerID, d.file.Length)
+		if err != nil {
+			output.Errorf("Error announcing to %s: %v", tracker, err)
+			continue
+		}
+		for _, peer := range found {
+			if !seen[peer] {
+				seen[peer] = true
+				peers = append(peers, peer)
+			}
+		}
+	}
+
+	addresses := make(chan string, len(peers))
+	for _, peer := range peers {
+		addresses <- peer
+	}
+	close(addresses)
+
+	var wg sync.WaitGroup
+	for i := 0; i < maxPeers && i < len(peers); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for address := range addresses {
+				if ctx.Err() != nil || len(d.remaining()) == 0 {
+					return
+				}
+				if err := d.downloadFromPeer(ctx, address, peerID); err != nil {
+					output.Printf("Peer %s: %v\n", address, err)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+// downloadFromPeer downloads every missing piece the peer has
+func (d *download) downloadFromPeer(ctx context.Context, address string, peerID [20]byte) error {
+	peer, err := dialPeer(ctx, address, d.meta.InfoHash, peerID, d.bucket)
+	if err != nil {
+		return err
+	}
+	defer peer.close()
+
+	if err := peer.waitReady(); err != nil {
+		return err
+	}
+
+	for {
+		index, ok := d.claim(peer)
+		if !ok {
+			return nil
+		}
+
+		data, err := peer.downloadPiece(index, d.meta.pieceSize(index))
+		if err == nil && sha1.Sum(data) != d.meta.Pieces[index] {
+			err = fmt.Errorf("piece %d failed the hash check", index)
+		}
+		if err != nil {
+			d.release(index)
+			return err
+		}
+
+		if err := d.writePiece(index, int64(index)*d.meta.PieceLength, data); err != nil {
+			d.release(index)
+			return err
+		}
+	}
+}
+
+// downloadFromWebSeed fetches the bytes of the file inside a piece over HTTP. The piece
+// hash is only checked when the piece lies entirely inside the file.
+func (d *download) downloadFromWebSeed(ctx context.Context, webSeed string, index int) error {
+	pieceStart := int64(index) * d.meta.PieceLength
+	pieceEnd := pieceStart + d.meta.pieceSize(index)
+
+	start := max64(pieceStart, d.file.Offset)
+	end := min64(pieceEnd, d.file.Offset+d.file.Length)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, webSeed, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Range", fmt.Sprintf("bytes=%d-%d", start-d.file.Offset, end-d.file.Offset-1))
+	if d.authorize != nil {
+		d.authorize(req)
+	}
+
+	resp, err := network.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusPartialContent {
+		return fmt.Errorf("web seed returned %s", resp.Status)
+	}
+
+	data, err := io.ReadAll(network.LimitReader(ctx, io.LimitReader(resp.Body, end-start), d.bucket))
+	if err != nil {
+		return err
+	}
+	if int64(len(data)) != end-start {
+		return fmt.Errorf("web seed sent %d bytes of piece %d, expected %d", len(data), index, end-start)
+	}
+
+	if start == pieceStart && end == pieceEnd && sha1.Sum(data) != d.meta.Pieces[index] {
+		return fmt.Errorf("piece %d from the web seed failed the hash check", index)
+	}
+
+	return d.writePiece(index, start, data)
+}
+
+// claim picks the first missing piece the peer has that no other peer is downloading
+func (d *download) claim(peer *peerConn) (int, bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	first := int(d.file.Offset / d.meta.PieceLength)
+	last := int((d.file.Offset + d.file.Length - 1) / d.meta.PieceLength)
+	for index := first; index <= last; index++ {
+		if d.pending[index] && !d.inProgress[index] && peer.hasPiece(index) {
+			d.inProgress[index] = true
+			return index, true
+		}
+	}
+	return 0, false
+}
+
+// release puts back a piece that failed, so another source can send it
+func (d *download) release(index int) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	delete(d.inProgress, index)
+}
+
+// remaining returns the pieces still missing, in order
+func (d *download) remaining() []int {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	var indexes []int
+	first := int(d.file.Offset / d.meta.PieceLength)
+	last := int((d.file.Offset + d.file.Length - 1) / d.meta.PieceLength)
+	for index := first; index <= last; index++ {
+		if d.pending[index] {
+			indexes = append(indexes, index)
+		}
+	}
+	return indexes
+}
+
+// writePiece writes the part of data, starting at offset of the torrent, that belongs to the file
+func (d *download) writePiece(index int, offset int64, data []byte) error {
+	start := max64(offset, d.file.Offset)
+	end := min64(offset+int64(len(data)), d.file.Offset+d.file.Length)
+
+	if _, err := d.out.WriteAt(data[start-offset:end-offset], start-d.file.Offset); err != nil {
+		return err
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	delete(d.pending, index)
+	delete(d.inProgress, index)
+	d.written += end - start
+	d.progress(d.written, d.file.Length)
+
+	return nil
+}
+
+func max64(a, b int64) int64 {
+	if a > b {
+		return a
+	}
+	return b
+}
+
+func min64(a, b int64) int64 {
+	if a < b {
+		return a
+	}
+	return b
+}
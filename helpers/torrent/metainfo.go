@@ -0,0 +1,149 @@
+package torrent
+
+import (
+	"crypto/sha1"
+	"fmt"
+	"strings"
+)
+
+// FileEntry is a file inside a torrent, placed at Offset of the concatenated data
+type FileEntry struct {
+	Path   string
+	Length int64
+	Offset int64
+}
+
+// MetaInfo holds the parts of a .torrent file needed to download from it
+type MetaInfo struct {
+	Announce    []string
+	InfoHash    [20]byte
+	Name        string
+	PieceLength int64
+	Pieces      [][20]byte
+	Files       []FileEntry
+	WebSeeds    []string
+	TotalLength int64
+}
+
+// ParseMetaInfo reads a .torrent file.
+func ParseMetaInfo(data []byte) (*MetaInfo, error) {
+	d := &decoder{data: data}
+	root, err := d.value()
+	if err != nil {
+		return nil, err
+	}
+
+	dict, ok := root.(map[string]interface{})
+	if !ok || d.infoRaw == nil {
+		return nil, fmt.Errorf("torrent has no info dictionary")
+	}
+	info, ok := dict["info"].(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("torrent info is not a dictionary")
+	}
+
+	meta := &MetaInfo{InfoHash: sha1.Sum(d.infoRaw)}
+
+	if announce, ok := dict["announce"].(string); ok {
+		meta.Announce = append(meta.Announce, announce)
+	}
+	if tiers, ok := dict["announce-list"].([]interface{}); ok {
+		for _, tier := range tiers {
+			urls, _ := tier.([]interface{})
+			for _, url := range urls {
+				if url, ok := url.(string); ok && !contains(meta.Announce, url) {
+					meta.Announce = append(meta.Announce, url)
+				}
+			}
+		}
+	}
+
+	switch seeds := dict["url-list"].(type) {
+	case string:
+		meta.WebSeeds = []string{seeds}
+	case []interface{}:
+		for _, seed := range seeds {
+			if seed, ok := seed.(string); ok {
+				meta.WebSeeds = append(meta.WebSeeds, seed)
+			}
+		}
+	}
+
+	meta.Name, _ = info["name"].(string)
+	meta.PieceLength, _ = info["piece length"].(int64)
+	if meta.PieceLength <= 0 {
+		return nil, fmt.Errorf("invalid piece length")
+	}
+
+	pieces, _ := info["pieces"].(string)
+	if len(pieces)%20 != 0 {
+		return nil, fmt.Errorf("invalid pieces hashes")
+	}
+	for i := 0; i < len(pieces); i += 20 {
+		var hash [20]byte
+		copy(hash[:], pieces[i:i+20])
+		meta.Pieces = append(meta.Pieces, hash)
+	}
+
+	if length, ok := info["length"].(int64); ok {
+		// Single file torrent
+		meta.Files = []FileEntry{{Path: meta.Name, Length: length}}
+		meta.TotalLength = length
+	} else {
+		files, _ := info["files"].([]interface{})
+		for _, entry := range files {
+			file, _ := entry.(map[string]interface{})
+			length, _ := file["length"].(int64)
+
+			var parts []string
+			pathList, _ := file["path"].([]interface{})
+			for _, part := range pathList {
+				if part, ok := part.(string); ok {
+					parts = append(parts, part)
+				}
+			}
+
+			meta.Files = append(meta.Files, FileEntry{
+				Path:   strings.Join(parts, "/"),
+				Length: length,
+				Offset: meta.TotalLength,
+			})
+			meta.TotalLength += length
+		}
+	}
+
+	expectedPieces := (meta.TotalLength + meta.PieceLength - 1) / meta.PieceLength
+	if int64(len(meta.Pieces)) != expectedPieces {
+		return nil, fmt.Errorf("torrent has %d pieces, expected %d", len(meta.Pieces), expectedPieces)
+	}
+
+	return meta, nil
+}
+
+// FindFile returns the entry of a file by its path inside the torrent.
+func (m *MetaInfo) FindFile(path string) (FileEntry, bool) {
+	for _, file := range m.Files {
+		if file.Path == path {
+			return file, true
+		}
+	}
+	return FileEntry{}, false
+}
+
+// pieceSize returns the length of a piece, the last one being shorter
+func (m *MetaInfo) pieceSize(index int) int64 {
+	start := int64(index) * m.PieceLength
+	if start+m.PieceLength > m.TotalLength {
+		return m.TotalLength - start
+	}
+	return m.PieceLength
+}
+
+func contains(list []string, value string) bool {
+	for _, item := range list {
+		if item == value {
+			return true
+		}
+	}
+	return false
+}
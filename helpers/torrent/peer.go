@@ -0,0 +1,247 @@
+package torrent
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"handheldui/helpers/network"
+	"io"
+	"net"
+	"time"
+)
+
+// Peer wire message ids
+const (
+	msgChoke      = 0
+	msgUnchoke    = 1
+	msgInterested = 2
+	msgHave       = 4
+	msgBitfield   = 5
+	msgRequest    = 6
+	msgPiece      = 7
+)
+
+const (
+	protocolName = "BitTorrent protocol"
+	blockSize    = 16 * 1024
+	// maxPipeline is how many block requests are sent before waiting for the answers
+	maxPipeline = 5
+	peerTimeout = 30 * time.Second
+)
+
+// peerConn is a connection to a peer, used to request pieces one at a time
+type peerConn struct {
+	conn     net.Conn
+	reader   io.Reader
+	bitfield []byte
+	choked   bool
+	// closed stops the goroutine closing the connection on cancellation
+	closed chan struct{}
+}
+
+// dialPeer connects to a peer and exchanges the handshake. Peers are dialed directly,
+// without the proxy of the HTTP requests.
+func dialPeer(ctx context.Context, address string, infoHash, peerID [20]byte, bucket *network.Bucket) (*peerConn, error) {
+	dialer := net.Dialer{Timeout: 10 * time.Second}
+	conn, err := dialer.DialContext(ctx, "tcp", address)
+	if err != nil {
+		return nil, err
+	}
+
+	peer := &peerConn{
+		conn:   conn,
+		reader: network.LimitReader(ctx, conn, bucket),
+		choked: true,
+		closed: make(chan struct{}),
+	}
+
+	// Closes the connection when the download is cancelled, unblocking any read
+	go func() {
+		select {
+		case <-ctx.Done():
+			conn.Close()
+		case <-peer.closed:
+		}
+	}()
+
+	if err := peer.handshake(address, infoHash, peerID); err != nil {
+		peer.close()
+		return nil, err
+	}
+
+	return peer, nil
+}
+
+// handshake exchanges the handshake and tells the peer we want its pieces
+func (p *peerConn) handshake(address string, infoHash, peerID [20]byte) error {
+	p.conn.SetDeadline(time.Now().Add(peerTimeout))
+
+	handshake := make([]byte, 0, 68)
+	handshake = append(handshake, byte(len(protocolName)))
+	handshake = append(handshake, protocolName...)
+	handshake = append(handshake, make([]byte, 8)...)
+	handshake = append(handshake, infoHash[:]...)
+	handshake = append(handshake, peerID[:]...)
+	if _, err := p.conn.Write(handshake); err != nil {
+		return err
+	}
+
+	reply := make([]byte, 68)
+	if _, err := io.ReadFull(p.conn, reply); err != nil {
+		return err
+	}
+	if reply[0] != byte(len(protocolName)) || string(reply[1:20]) != protocolName || !bytes.Equal(reply[28:48], infoHash[:]) {
+		return fmt.Errorf("invalid handshake from %s", address)
+	}
+
+	return p.send(msgInterested, nil)
+}
+
+func (p *peerConn) close() {
+	close(p.closed)
+	p.conn.Close()
+}
+
+func (p *peerConn) send(id byte, payload []byte) error {
+	message := make([]byte, 5+len(payload))
+	binary.BigEndian.PutUint32(message, uint32(1+len(payload)))
+	message[4] = id
+	copy(message[5:], payload)
+	_, err := p.conn.Write(message)
+	return err
+}
+
+// readMessage returns the next message, with ok false for keep-alives
+func (p *peerConn) readMessage() (id byte, payload []byte, ok bool, err error) {
+	var header [4]byte
+	if _, err := io.ReadFull(p.reader, header[:]); err != nil {
+		return 0, nil, false, err
+	}
+
+	length := binary.BigEndian.Uint32(header[:])
+	if length == 0 {
+		return 0, nil, false, nil
+	}
+	if length > 1<<20 {
+		return 0, nil, false, fmt.Errorf("message too large: %d", length)
+	}
+
+	message := make([]byte, length)
+	if _, err := io.ReadFull(p.reader, message); err != nil {
+		return 0, nil, false, err
+	}
+
+	return message[0], message[1:], true, nil
+}
+
+// handle updates the peer state from a message that is not a piece
+func (p *peerConn) handle(id byte, payload []byte) {
+	switch id {
+	case msgChoke:
+		p.choked = true
+	case msgUnchoke:
+		p.choked = false
+	case msgBitfield:
+		p.bitfield = append([]byte{}, payload...)
+	case msgHave:
+		if len(payload) == 4 {
+			p.setPiece(int(binary.BigEndian.Uint32(payload)))
+		}
+	}
+}
+
+func (p *peerConn) hasPiece(index int) bool {
+	byteIndex := index / 8
+	if byteIndex >= len(p.bitfield) {
+		return false
+	}
+	return p.bitfield[byteIndex]>>(7-uint(index%8))&1 != 0
+}
+
+func (p *peerConn) setPiece(index int) {
+	byteIndex := index / 8
+	for len(p.bitfield) <= byteIndex {
+		p.bitfield = append(p.bitfield, 0)
+	}
+	p.bitfield[byteIndex] |= 1 << (7 - uint(index%8))
+}
+
+// waitReady reads messages until the peer unchokes us and its bitfield is known
+func (p *peerConn) waitReady() error {
+	p.conn.SetDeadline(time.Now().Add(peerTimeout))
+	for p.choked {
+		id, payload, ok, err := p.readMessage()
+		if err != nil {
+			return err
+		}
+		if ok {
+			p.handle(id, payload)
+		}
+	}
+	return nil
+}
+
+// downloadPiece requests every block of a piece, keeping a few requests in flight. Blocks
+// are tracked by offset, so repeated blocks are only counted once.
+func (p *peerConn) downloadPiece(index int, length int64) ([]byte, error) {
+	data := make([]byte, length)
+	blocks := make(map[int64]bool)
+	var requested, received int64
+
+	for received < length {
+		if p.choked {
+			return nil, fmt.Errorf("choked by peer")
+		}
+
+		// Keeps the pipeline full
+		for requested < length && requested-received < maxPipeline*blockSize {
+			size := int64(blockSize)
+			if length-requested < size {
+				size = length - requested
+			}
+
+			payload := make([]byte, 12)
+			binary.BigEndian.PutUint32(payload[0:], uint32(index))
+			binary.BigEndian.PutUint32(payload[4:], uint32(requested))
+			binary.BigEndian.PutUint32(payload[8:], uint32(size))
+			if err := p.send(msgRequest, payload); err != nil {
+				return nil, err
+			}
+			requested += size
+		}
+
+		p.conn.SetDeadline(time.Now().Add(peerTimeout))
+		id, payload, ok, err := p.readMessage()
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			continue
+		}
+
+		if id != msgPiece {
+			p.handle(id, payload)
+			continue
+		}
+
+		if len(payload) < 8 || int(binary.BigEndian.Uint32(payload[0:])) != index {
+			continue
+		}
+
+		begin := int64(binary.BigEndian.Uint32(payload[4:]))
+		block := payload[8:]
+		if begin%blockSize != 0 || begin >= requested || int64(len(block)) != min64(blockSize, length-begin) {
+			return nil, fmt.Errorf("unexpected block at %d of piece %d", begin, index)
+		}
+		if blocks[begin] {
+			continue
+		}
+
+		copy(data[begin:], block)
+		blocks[begin] = true
+		received += int64(len(block))
+	}
+
+	return data, nil
+}
@@ -0,0 +1,194 @@
+package torrent
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"handheldui/vars"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// fakePeer seeds data over the peer wire protocol. With duplicate set, every block is
+// sent twice.
+type fakePeer struct {
+	meta      *MetaInfo
+	data      []byte
+	duplicate bool
+	listener  net.Listener
+}
+
+func newFakePeer(t *testing.T, meta *MetaInfo, data []byte, duplicate bool) *fakePeer {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { listener.Close() })
+
+	peer := &fakePeer{meta: meta, data: data, duplicate: duplicate, listener: listener}
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go peer.serve(conn)
+		}
+	}()
+	return peer
+}
+
+func (p *fakePeer) serve(conn net.Conn) {
+	defer conn.Close()
+
+	handshake := make([]byte, 68)
+	if _, err := io.ReadFull(conn, handshake); err != nil {
+		return
+	}
+	copy(handshake[48:], "-FAKE00-seeder000000")
+	conn.Write(handshake)
+
+	bitfield := make([]byte, (len(p.meta.Pieces)+7)/8)
+	for index := range p.meta.Pieces {
+		bitfield[index/8] |= 1 << (7 - uint(index%8))
+	}
+	writeMessage(conn, msgBitfield, bitfield)
+	writeMessage(conn, msgUnchoke, nil)
+
+	for {
+		var header [4]byte
+		if _, err := io.ReadFull(conn, header[:]); err != nil {
+			return
+		}
+		message := make([]byte, binary.BigEndian.Uint32(header[:]))
+		if _, err := io.ReadFull(conn, message); err != nil {
+			return
+		}
+		if len(message) != 13 || message[0] != msgRequest {
+			continue
+		}
+
+		index := binary.BigEndian.Uint32(message[1:])
+		begin := binary.BigEndian.Uint32(message[5:])
+		length := binary.BigEndian.Uint32(message[9:])
+		start := int64(index)*p.meta.PieceLength + int64(begin)
+
+		payload := make([]byte, 8, 8+length)
+		binary.BigEndian.PutUint32(payload[0:], index)
+		binary.BigEndian.PutUint32(payload[4:], begin)
+		payload = append(payload, p.data[start:start+int64(length)]...)
+
+		writeMessage(conn, msgPiece, payload)
+		if p.duplicate {
+			writeMessage(conn, msgPiece, payload)
+		}
+	}
+}
+
+func writeMessage(conn net.Conn, id byte, payload []byte) {
+	message := make([]byte, 5+len(payload))
+	binary.BigEndian.PutUint32(message, uint32(1+len(payload)))
+	message[4] = id
+	copy(message[5:], payload)
+	conn.Write(message)
+}
+
+// testData returns the content of a torrent of a few pieces, each of several blocks
+func testData() []byte {
+	data := make([]byte, 3*blockSize*2+1000)
+	for i := range data {
+		data[i] = byte(i * 31)
+	}
+	return data
+}
+
+func TestDownloadPiece(t *testing.T) {
+	data := testData()
+	torrent, _ := makeTorrent("game.bin", data, 2*blockSize+512, "http://tracker.invalid/announce")
+	meta, err := ParseMetaInfo(torrent)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, duplicate := range []bool{false, true} {
+		seeder := newFakePeer(t, meta, data, duplicate)
+
+		var peerID [20]byte
+		peer, err := dialPeer(context.Background(), seeder.listener.Addr().String(), meta.InfoHash, peerID, nil)
+		if err != nil {
+			t.Fatalf("dialPeer() error = %v", err)
+		}
+
+		if err := peer.waitReady(); err != nil {
+			t.Fatalf("waitReady() error = %v", err)
+		}
+
+		for index := range meta.Pieces {
+			if !peer.hasPiece(index) {
+				t.Fatalf("peer doesn't have piece %d after its bitfield", index)
+			}
+
+			piece, err := peer.downloadPiece(index, meta.pieceSize(index))
+			if err != nil {
+				t.Fatalf("downloadPiece(%d) with duplicate blocks %v, error = %v", index, duplicate, err)
+			}
+
+			start := int64(index) * meta.PieceLength
+			if !bytes.Equal(piece, data[start:start+meta.pieceSize(index)]) {
+				t.Errorf("piece %d with duplicate blocks %v differs from the seeded data", index, duplicate)
+			}
+		}
+
+		peer.close()
+	}
+}
+
+func TestDownload(t *testing.T) {
+	previous := vars.Config()
+	vars.SetConfig(&vars.ConfigDefinition{})
+	defer func() { vars.SetConfig(previous) }()
+
+	data := testData()
+	meta := &MetaInfo{}
+
+	tracker := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		seeder := newFakePeer(t, meta, data, false)
+		address := seeder.listener.Addr().(*net.TCPAddr)
+
+		compact := append(address.IP.To4(), byte(address.Port>>8), byte(address.Port))
+		w.Write([]byte(encode(map[string]interface{}{"peers": string(compact)})))
+	}))
+	defer tracker.Close()
+
+	torrent, _ := makeTorrent("game.bin", data, 2*blockSize, tracker.URL+"/announce")
+	parsed, err := ParseMetaInfo(torrent)
+	if err != nil {
+		t.Fatal(err)
+	}
+	*meta = *parsed
+
+	destPath := filepath.Join(t.TempDir(), "game.bin")
+	var written int64
+	err = Download(context.Background(), meta, "game.bin", destPath, "", nil, func(downloaded, total int64) {
+		written = downloaded
+	})
+	if err != nil {
+		t.Fatalf("Download() error = %v", err)
+	}
+
+	got, err := os.ReadFile(destPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, data) {
+		t.Error("downloaded file differs from the seeded data")
+	}
+	if written != int64(len(data)) {
+		t.Errorf("progress reported %d bytes, want %d", written, len(data))
+	}
+}
@@ -0,0 +1,88 @@
+package torrent
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"handheldui/helpers/network"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// announce asks an HTTP tracker for the peers of a torrent. UDP trackers are not supported.
+func announce(ctx context.Context, tracker string, meta *MetaInfo, peerID [20]byte, left int64) ([]string, error) {
+	if !strings.HasPrefix(tracker, "http://") && !strings.HasPrefix(tracker, "https://") {
+		return nil, fmt.Errorf("unsupported tracker %s", tracker)
+	}
+
+	params := url.Values{
+		"info_hash":  {string(meta.InfoHash[:])},
+		"peer_id":    {string(peerID[:])},
+		"port":       {"6881"},
+		"uploaded":   {"0"},
+		"downloaded": {"0"},
+		"left":       {strconv.FormatInt(left, 10)},
+		"compact":    {"1"},
+		"event":      {"started"},
+	}
+
+	separator := "?"
+	if strings.Contains(tracker, "?") {
+		separator = "&"
+	}
+
+	resp, err := network.GetWithContext(ctx, tracker+separator+params.Encode())
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("tracker returned %s", resp.Status)
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	decoded, err := Decode(body)
+	if err != nil {
+		return nil, err
+	}
+
+	dict, ok := decoded.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("invalid tracker response")
+	}
+
+	if reason, ok := dict["failure reason"].(string); ok {
+		return nil, fmt.Errorf("tracker failure: %s", reason)
+	}
+
+	var peers []string
+	switch list := dict["peers"].(type) {
+	case string:
+		// Compact format, 4 bytes of IP and 2 of port for each peer
+		for i := 0; i+6 <= len(list); i += 6 {
+			ip := net.IP([]byte(list[i : i+4]))
+			port := binary.BigEndian.Uint16([]byte(list[i+4 : i+6]))
+			peers = append(peers, net.JoinHostPort(ip.String(), strconv.Itoa(int(port))))
+		}
+	case []interface{}:
+		for _, entry := range list {
+			peer, _ := entry.(map[string]interface{})
+			ip, _ := peer["ip"].(string)
+			port, _ := peer["port"].(int64)
+			if ip != "" && port > 0 {
+				peers = append(peers, net.JoinHostPort(ip, strconv.FormatInt(port, 10)))
+			}
+		}
+	}
+
+	return peers, nil
+}
@@ -0,0 +1,90 @@
+package torrent
+
+import (
+	"context"
+	"handheldui/vars"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func TestAnnounce(t *testing.T) {
+	previous := vars.Config()
+	vars.SetConfig(&vars.ConfigDefinition{})
+	defer func() { vars.SetConfig(previous) }()
+
+	meta := &MetaInfo{InfoHash: [20]byte{1, 2, 3}}
+	var peerID [20]byte
+	copy(peerID[:], "-HU0001-")
+
+	tests := []struct {
+		name    string
+		status  int
+		body    string
+		want    []string
+		wantErr bool
+	}{
+		{
+			name: "compact peers",
+			body: encode(map[string]interface{}{
+				"interval": 1800,
+				"peers":    "\x7f\x00\x00\x01\x1a\xe1\x0a\x00\x00\x02\x00\x50",
+			}),
+			want: []string{"127.0.0.1:6881", "10.0.0.2:80"},
+		},
+		{
+			name: "peer dictionaries",
+			body: encode(map[string]interface{}{
+				"peers": []interface{}{
+					map[string]interface{}{"ip": "192.168.0.1", "port": 51413},
+					map[string]interface{}{"ip": "", "port": 1},
+				},
+			}),
+			want: []string{"192.168.0.1:51413"},
+		},
+		{
+			name:    "failure reason",
+			body:    encode(map[string]interface{}{"failure reason": "unregistered torrent"}),
+			wantErr: true,
+		},
+		{name: "not bencoded", body: "<html></html>", wantErr: true},
+		{name: "error status", status: http.StatusNotFound, wantErr: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var query map[string][]string
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				query = r.URL.Query()
+				if test.status != 0 {
+					w.WriteHeader(test.status)
+				}
+				w.Write([]byte(test.body))
+			}))
+			defer server.Close()
+
+			got, err := announce(context.Background(), server.URL+"/announce?key=1", meta, peerID, 1234)
+			if test.wantErr {
+				if err == nil {
+					t.Fatalf("announce() = %v, want an error", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("announce() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("announce() = %v, want %v", got, test.want)
+			}
+
+			if query["info_hash"][0] != string(meta.InfoHash[:]) || query["left"][0] != "1234" || query["key"][0] != "1" {
+				t.Errorf("unexpected announce query %v", query)
+			}
+		})
+	}
+
+	if _, err := announce(context.Background(), "udp://tracker.example:80", meta, peerID, 0); err == nil {
+		t.Error("announce() to an UDP tracker succeeded, want an error")
+	}
+}
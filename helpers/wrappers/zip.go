@@ -16,7 +16,7 @@ func UnzipFile(src, dest string) error {
 	}
 
 	// Prepare the unzip command to extract the file
-	output.Sprintf("%s %s %s %s", "unzip", "-o", src, "-d", dest)
+	output.Sprintf("%s %s %s %s %s", "unzip", "-o", src, "-d", dest)
 	cmd := exec.Command("unzip", "-o", src, "-d", dest)
 
 	// Redirect stdout and stderr to monitor the progress
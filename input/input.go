@@ -23,8 +23,12 @@ func listenForKeyboardEvents() {
 	keyMappings := map[sdl.Scancode]string{
 		sdl.SCANCODE_DOWN:     "DOWN",
 		sdl.SCANCODE_UP:       "UP",
+		sdl.SCANCODE_LEFT:     "LEFT",
+		sdl.SCANCODE_RIGHT:    "RIGHT",
 		sdl.SCANCODE_A:        "A",
 		sdl.SCANCODE_B:        "B",
+		sdl.SCANCODE_X:        "X",
+		sdl.SCANCODE_Y:        "Y",
 		sdl.SCANCODE_PAGEDOWN: "R1",
 		sdl.SCANCODE_PAGEUP:   "L1",
 	}
@@ -51,8 +55,12 @@ func listenForControllerEvents() {
 	controllerMappings := map[sdl.GameControllerButton]string{
 		sdl.CONTROLLER_BUTTON_DPAD_DOWN:     "DOWN",
 		sdl.CONTROLLER_BUTTON_DPAD_UP:       "UP",
+		sdl.CONTROLLER_BUTTON_DPAD_LEFT:     "LEFT",
+		sdl.CONTROLLER_BUTTON_DPAD_RIGHT:    "RIGHT",
 		sdl.CONTROLLER_BUTTON_A:             "B",
 		sdl.CONTROLLER_BUTTON_B:             "A",
+		sdl.CONTROLLER_BUTTON_X:             "Y",
+		sdl.CONTROLLER_BUTTON_Y:             "X",
 		sdl.CONTROLLER_BUTTON_LEFTSHOULDER:  "L1",
 		sdl.CONTROLLER_BUTTON_RIGHTSHOULDER: "R1",
 	}
@@ -89,10 +97,12 @@ func openController() *sdl.GameController {
 
 func getRespectiveSound(key string) string {
 	soundMappings := map[string]string{
-		"DOWN": "assets/sounds/SFX_UI_MenuSelections.wav",
-		"UP":   "assets/sounds/SFX_UI_MenuSelections.wav",
-		"A":    "assets/sounds/SFX_UI_Confirm.wav",
-		"B":    "assets/sounds/SFX_UI_Cancel.wav",
+		"DOWN":  "assets/sounds/SFX_UI_MenuSelections.wav",
+		"UP":    "assets/sounds/SFX_UI_MenuSelections.wav",
+		"LEFT":  "assets/sounds/SFX_UI_MenuSelections.wav",
+		"RIGHT": "assets/sounds/SFX_UI_MenuSelections.wav",
+		"A":     "assets/sounds/SFX_UI_Confirm.wav",
+		"B":     "assets/sounds/SFX_UI_Cancel.wav",
 	}
 	return soundMappings[key]
 }
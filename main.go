@@ -2,10 +2,12 @@ package main
 
 import (
 	_ "embed"
+	"fmt"
 	"log"
 	"os"
 	"runtime/debug"
 
+	"handheldui/helpers/network"
 	"handheldui/helpers/sdlutils"
 	"handheldui/input"
 	"handheldui/output"
@@ -25,6 +27,9 @@ var KenneySpace []byte
 //go:embed assets/fonts/NotoSans_Condensed-SemiBold.ttf
 var NotoSans []byte
 
+//go:embed assets/certs/cacert.pem
+var caBundle []byte
+
 func main() {
 	// Defer a function to handle panics and exit with -1
 	defer func() {
@@ -40,16 +45,67 @@ func main() {
 
 	var err error
 
-	configFilePath := "configs/config.json"
-	configFile, err := os.ReadFile(configFilePath)
+	overrides := vars.ParseOverrides(os.Args[1:])
+
+	var config *vars.ConfigDefinition
+	var configIssues []vars.ConfigIssue
+	configFilePath, err := vars.FindConfigFile(overrides.ConfigPath)
 	if err != nil {
-		log.Fatalf("Error reading config file: %v\n", err)
+		configIssues = []vars.ConfigIssue{{Message: err.Error()}}
+	} else if configFile, err := os.ReadFile(configFilePath); err != nil {
+		configIssues = []vars.ConfigIssue{{Message: fmt.Sprintf("error reading config file: %v", err)}}
+	} else {
+		config, configIssues = vars.ValidateConfig(configFile)
 	}
 
-	vars.Config, err = vars.LoadConfig(configFile)
+	// A broken config starts the app with the defaults, so the problems can be shown on
+	// screen. The file is only read again when the app restarts.
+	if vars.HasErrors(configIssues) {
+		config, err = vars.LoadConfig(vars.DefaultConfig)
+		if err != nil {
+			log.Fatalf("Error loading the default config: %v\n", err)
+		}
+	}
+	configIssues = append(configIssues, overrides.Apply(config)...)
 
-	if err != nil {
-		panic(err)
+	// The config is complete before it is published
+	vars.SetConfig(config)
+	if config.Platform != "" {
+		vars.SetCurrentPlatform(config.Platform)
+	}
+
+	// Credentials are optional and live in their own file, beside the config
+	vars.SecretsFilePath = vars.SecretsPath(configFilePath)
+	var secretsIssues []vars.ConfigIssue
+	vars.Secrets, secretsIssues = vars.ReadSecrets(vars.SecretsFilePath)
+	configIssues = append(configIssues, secretsIssues...)
+
+	download := vars.Config().Download
+	network.Configure(download.MaxRate, download.DownloadRate, download.RequestsPerSecond)
+
+	// The embedded bundle covers devices without usable root certificates
+	transport := network.TransportSettings{
+		Proxy:   vars.Config().Network.Proxy,
+		Bundles: [][]byte{caBundle},
+		Pins:    vars.Config().Network.Pins,
+	}
+	if vars.Config().Network.CABundle != "" {
+		transport.BundleFiles = []string{vars.Config().Network.CABundle}
+	}
+	if err := network.ConfigureTransport(transport); err != nil {
+		// Settings changed since they were checked, like a removed bundle, are reported
+		// and the app runs without them
+		configIssues = append(configIssues, vars.ConfigIssue{
+			Message: fmt.Sprintf("network settings: %v; requests are sent without the proxy, CA bundle and pins", err),
+			Warning: true,
+		})
+		if err := network.ConfigureTransport(network.TransportSettings{Bundles: [][]byte{caBundle}}); err != nil {
+			log.Fatalf("Error configuring network: %v\n", err)
+		}
+	}
+
+	for _, issue := range configIssues {
+		log.Printf("Config: %s\n", issue)
 	}
 
 	if err := sdlutils.InitSDL(); err != nil {
@@ -82,8 +138,8 @@ func main() {
 	}
 
 	windowTitle := "HandhelDB"
-	windowWidth := vars.Config.Screen.Width
-	windowHeight := vars.Config.Screen.Height
+	windowWidth := vars.Config().Screen.Width
+	windowHeight := vars.Config().Screen.Height
 
 	window, err := sdl.CreateWindow(windowTitle, sdl.WINDOWPOS_UNDEFINED, sdl.WINDOWPOS_UNDEFINED, windowWidth, windowHeight, sdl.WINDOW_SHOWN)
 
@@ -92,7 +148,10 @@ func main() {
 	}
 	defer window.Destroy()
 
-	output.PlaySound("assets/sounds/Retro_Mystic.ogg", 5, true)
+	output.SetVolume(vars.Config().Audio.Volume)
+	if vars.Config().Audio.Music {
+		output.PlaySound(screens.BackgroundMusic, 5, true)
+	}
 
 	renderer, err := sdl.CreateRenderer(window, -1, sdl.RENDERER_ACCELERATED)
 	if err != nil {
@@ -105,12 +164,45 @@ func main() {
 		panic(err)
 	}
 
-	repositoriesScreen, err := screens.NewRepositoriesScreen(renderer)
+	configErrorScreen, err := screens.NewConfigErrorScreen(renderer, configFilePath, configIssues)
+	if err != nil {
+		panic(err)
+	}
+	if len(configIssues) > 0 {
+		vars.CurrentScreen = "config_error_screen"
+	}
+
+	infoScreen, err := screens.NewInfoScreen(renderer)
+	if err != nil {
+		panic(err)
+	}
+
+	repositoriesScreen, err := screens.NewRepositoriesScreen(renderer, infoScreen)
+	if err != nil {
+		panic(err)
+	}
+
+	actionsScreen, err := screens.NewActionsScreen(renderer)
+	if err != nil {
+		panic(err)
+	}
+
+	filesScreen, err := screens.NewFilesScreen(renderer, infoScreen)
+	if err != nil {
+		panic(err)
+	}
+
+	syncScreen, err := screens.NewSyncScreen(renderer, filesScreen)
+	if err != nil {
+		panic(err)
+	}
+
+	searchScreen, err := screens.NewSearchScreen(renderer, filesScreen)
 	if err != nil {
 		panic(err)
 	}
 
-	filesScreen, err := screens.NewFilesScreen(renderer)
+	settingsScreen, err := screens.NewSettingsScreen(renderer, configFilePath, vars.DefaultConfig)
 	if err != nil {
 		panic(err)
 	}
@@ -137,8 +229,14 @@ func main() {
 
 	screensMap := map[string]func(){
 		"home_screen":         homeScreen.Draw,
+		"config_error_screen": configErrorScreen.Draw,
 		"repositories_screen": repositoriesScreen.Draw,
+		"actions_screen":      actionsScreen.Draw,
 		"files_screen":        filesScreen.Draw,
+		"sync_screen":         syncScreen.Draw,
+		"info_screen":         infoScreen.Draw,
+		"search_screen":       searchScreen.Draw,
+		"settings_screen":     settingsScreen.Draw,
 		"systems_screen":      systemsScreen.Draw,
 		"games_screen":        gamesScreen.Draw,
 		"overview_screen":     overviewScreen.Draw,
@@ -147,8 +245,14 @@ func main() {
 
 	inputHandlers := map[string]func(input.InputEvent){
 		"home_screen":         homeScreen.HandleInput,
+		"config_error_screen": configErrorScreen.HandleInput,
 		"repositories_screen": repositoriesScreen.HandleInput,
+		"actions_screen":      actionsScreen.HandleInput,
 		"files_screen":        filesScreen.HandleInput,
+		"sync_screen":         syncScreen.HandleInput,
+		"info_screen":         infoScreen.HandleInput,
+		"search_screen":       searchScreen.HandleInput,
+		"settings_screen":     settingsScreen.HandleInput,
 		"systems_screen":      systemsScreen.HandleInput,
 		"games_screen":        gamesScreen.HandleInput,
 		"overview_screen":     overviewScreen.HandleInput,
@@ -1,28 +1,31 @@
 package output
 
 import (
+	"fmt"
 	"handheldui/vars"
 	"log"
 )
 
 func Printf(format string, a ...any) (n int, err error) {
-	if vars.Config.Logs {
+	if vars.Config().Logs {
 		log.Printf(format, a...)
 		return len(format), nil
 	}
 	return 0, nil
 }
 
+// Errorf builds an error like fmt.Errorf, %w included, and logs its message. The format
+// is never given to the logger, which doesn't know %w.
 func Errorf(format string, a ...any) (err error) {
-	if vars.Config.Logs {
-		log.Printf("ERROR: "+format, a...)
-		return nil
+	err = fmt.Errorf(format, a...)
+	if vars.Config().Logs {
+		log.Print("ERROR: " + err.Error())
 	}
-	return nil
+	return err
 }
 
 func Sprintf(format string, a ...any) string {
-	if vars.Config.Logs {
+	if vars.Config().Logs {
 		log.Printf(format, a...)
 		return format
 	}
@@ -1,11 +1,12 @@
 package output
 
 import (
-	"time"
-
 	"github.com/veandco/go-sdl2/mix"
 )
 
+// musicChannel is reserved for the looping sounds, so the effects never cut the music
+const musicChannel = 0
+
 // PlaySound plays a sound file with specified volume and loop settings.
 func PlaySound(filename string, volume int, loop bool) {
 	go func() {
@@ -18,12 +19,19 @@ func PlaySound(filename string, volume int, loop bool) {
 		chunk.Volume(volume)
 
 		if loop {
-			for {
-				chunk.Play(-1, 0)
-				time.Sleep(time.Duration(chunk.LengthInMs()) * time.Millisecond)
-			}
+			chunk.Play(musicChannel, -1)
 		} else {
 			chunk.Play(-1, 0)
 		}
 	}()
 }
+
+// StopLoops stops the looping sounds, like the background music
+func StopLoops() {
+	mix.HaltChannel(musicChannel)
+}
+
+// SetVolume sets the volume of every sound, from 0 to 100
+func SetVolume(volume int) {
+	mix.Volume(-1, volume*mix.MAX_VOLUME/100)
+}
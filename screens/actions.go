@@ -0,0 +1,183 @@
+package screens
+
+import (
+	"context"
+	"fmt"
+	"handheldui/components"
+	"handheldui/helpers/sdlutils"
+	"handheldui/input"
+	"handheldui/output"
+	"handheldui/services"
+	"handheldui/vars"
+
+	"github.com/veandco/go-sdl2/sdl"
+)
+
+type ActionsScreen struct {
+	initialized   bool
+	renderer      *sdl.Renderer
+	listComponent *components.ListComponent
+	progressBar   *components.ProgressBarComponent
+	isRunning     bool
+	runningLabel  string
+	message       string
+	cancelAction  context.CancelFunc
+}
+
+func NewActionsScreen(renderer *sdl.Renderer) (*ActionsScreen, error) {
+	listComponent := components.NewListComponent(
+		renderer,
+		vars.Config().Screen.MaxListItens,
+		vars.Config().Screen.MaxListItemWidth,
+		func(index int, item map[string]interface{}) string {
+			return item["name"].(string)
+		})
+
+	progressBar := components.NewProgressBarComponent(renderer, 300, 20, 490, 320, vars.Colors.WHITE, vars.Colors.SECONDARY)
+
+	return &ActionsScreen{
+		renderer:      renderer,
+		listComponent: listComponent,
+		progressBar:   progressBar,
+	}, nil
+}
+
+func (a *ActionsScreen) InitActions() {
+	if a.initialized {
+		return
+	}
+
+	repo := vars.Config().Repositories[vars.CurrentRepo]
+
+	items := []map[string]interface{}{
+		{"name": "Browse files", "value": "browse"},
+		{"name": "Sync", "value": "sync"},
+	}
+
+	// Scraping needs the database system of the repository and an image layout for the platform
+	if _, ok := vars.Config().Artwork[vars.CurrentPlatform()]; ok && repo.System != "" {
+		items = append(items, map[string]interface{}{"name": "Scrape library", "value": "scrape"})
+	}
+
+	if len(repo.Exports) > 0 {
+		items = append(items, map[string]interface{}{"name": "Export lists", "value": "export"})
+	}
+
+	a.listComponent.SetItems(items)
+
+	a.initialized = true
+}
+
+func (a *ActionsScreen) HandleInput(event input.InputEvent) {
+	if a.isRunning {
+		// Only B is accepted while an action runs, cancelling it
+		if event.KeyCode == "B" && a.cancelAction != nil {
+			a.cancelAction()
+		}
+		return
+	}
+
+	a.message = ""
+
+	switch event.KeyCode {
+	case "DOWN":
+		a.listComponent.ScrollDown()
+	case "UP":
+		a.listComponent.ScrollUp()
+	case "A":
+		selectedItem := a.listComponent.GetItems()[a.listComponent.GetSelectedIndex()]
+		switch selectedItem["value"].(string) {
+		case "browse":
+			a.initialized = false
+			vars.CurrentScreen = "files_screen"
+		case "sync":
+			a.initialized = false
+			vars.CurrentScreen = "sync_screen"
+		case "scrape":
+			go a.scrapeLibrary()
+		case "export":
+			go a.exportLists()
+		}
+	case "B":
+		a.initialized = false
+		vars.CurrentScreen = "repositories_screen"
+	}
+}
+
+// scrapeLibrary exports the artwork of the ROMs already inside the repository path
+func (a *ActionsScreen) scrapeLibrary() {
+	ctx, cancel := context.WithCancel(context.Background())
+	a.cancelAction = cancel
+	a.isRunning = true
+	a.progressBar.SetProgress(0.0)
+
+	repo := vars.Config().Repositories[vars.CurrentRepo]
+	artwork := vars.Config().Artwork[vars.CurrentPlatform()]
+
+	exported, err := services.ScrapeLibrary(ctx, repo, artwork, func(name string, done, total int) {
+		a.runningLabel = fmt.Sprintf("Scraping %d of %d: %s", done+1, total, name)
+		if total > 0 {
+			a.progressBar.SetProgress(float64(done) / float64(total) * 100)
+		}
+	})
+	if err != nil {
+		output.Errorf("Error scraping library: %v", err)
+		a.message = fmt.Sprintf("Scrape stopped: %v (%d images exported)", err, exported)
+	} else {
+		a.message = fmt.Sprintf("%d images exported", exported)
+	}
+
+	cancel()
+	a.isRunning = false
+	a.cancelAction = nil
+	a.runningLabel = ""
+}
+
+// exportLists writes every list configured for the repository
+func (a *ActionsScreen) exportLists() {
+	a.isRunning = true
+	a.runningLabel = "Exporting lists"
+	a.progressBar.SetProgress(0.0)
+
+	if err := services.ExportLists(vars.CurrentRepo, vars.Config().Repositories[vars.CurrentRepo], false); err != nil {
+		output.Errorf("Error exporting lists: %v", err)
+		a.message = fmt.Sprintf("Export failed: %v", err)
+	} else {
+		a.message = "Lists exported"
+	}
+
+	a.isRunning = false
+	a.runningLabel = ""
+}
+
+func (a *ActionsScreen) Draw() {
+	a.InitActions()
+
+	a.renderer.SetDrawColor(255, 255, 255, 255)
+	a.renderer.Clear()
+
+	if a.isRunning {
+		sdlutils.RenderTextureCover(a.renderer, "assets/textures/bg.bmp")
+
+		a.progressBar.Draw()
+
+		sdlutils.DrawText(a.renderer, a.runningLabel, sdl.Point{X: 25, Y: 25}, vars.Colors.WHITE, vars.HeaderFont)
+
+		sdlutils.RenderTextureCartesian(a.renderer, "assets/textures/$aspect_ratio/ui_controls_download.bmp", "Q3", "Q4")
+	} else {
+		sdlutils.RenderTextureCartesian(a.renderer, "assets/textures/bg.bmp", "Q2", "Q4")
+
+		sdlutils.DrawText(a.renderer, repositoryTitle(vars.Config().Repositories[vars.CurrentRepo]), sdl.Point{X: 25, Y: 25}, vars.Colors.WHITE, vars.HeaderFont)
+
+		// Draws the result of the last action
+		if a.message != "" {
+			sdlutils.DrawText(a.renderer, a.message, sdl.Point{X: 25, Y: 60}, vars.Colors.WHITE, vars.LongTextFont)
+		}
+
+		a.listComponent.Draw(vars.Colors.SECONDARY, vars.Colors.WHITE)
+
+		sdlutils.RenderTextureCartesian(a.renderer, "assets/textures/$aspect_ratio/ui_controls.bmp", "Q3", "Q4")
+	}
+
+	a.renderer.Present()
+}
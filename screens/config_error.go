@@ -0,0 +1,91 @@
+package screens
+
+import (
+	"fmt"
+	"handheldui/components"
+	"handheldui/helpers/sdlutils"
+	"handheldui/input"
+	"handheldui/vars"
+	"os"
+	"strings"
+
+	"github.com/veandco/go-sdl2/sdl"
+)
+
+type ConfigErrorScreen struct {
+	renderer      *sdl.Renderer
+	textComponent *components.TextComponent
+	issues        []vars.ConfigIssue
+	configPath    string
+	initialized   bool
+}
+
+// NewConfigErrorScreen shows the problems of the config file. The app already runs with
+// the defaults when the config has errors, until the file is fixed and the app restarted,
+// or with the config itself when it only has warnings.
+func NewConfigErrorScreen(renderer *sdl.Renderer, configPath string, issues []vars.ConfigIssue) (*ConfigErrorScreen, error) {
+	return &ConfigErrorScreen{
+		renderer:   renderer,
+		configPath: configPath,
+		issues:     issues,
+	}, nil
+}
+
+func (c *ConfigErrorScreen) InitConfigError() {
+	if c.initialized {
+		return
+	}
+
+	var lines []string
+	for _, issue := range c.issues {
+		prefix := "Error"
+		if issue.Warning {
+			prefix = "Warning"
+		}
+		lines = append(lines, fmt.Sprintf("%s: %s", prefix, issue))
+	}
+
+	c.textComponent = components.NewTextComponent(c.renderer, strings.Join(lines, "\n"), vars.LongTextFont, vars.Config().Screen.MaxLines, int(vars.Config().Screen.Width)-20)
+
+	c.initialized = true
+}
+
+func (c *ConfigErrorScreen) HandleInput(event input.InputEvent) {
+	switch event.KeyCode {
+	case "DOWN":
+		c.textComponent.ScrollDown()
+	case "UP":
+		c.textComponent.ScrollUp()
+	case "A":
+		vars.CurrentScreen = "home_screen"
+	case "B":
+		os.Exit(0)
+	}
+}
+
+func (c *ConfigErrorScreen) Draw() {
+	c.InitConfigError()
+
+	c.renderer.SetDrawColor(0, 0, 0, 255) // Background color
+	c.renderer.Clear()
+
+	sdlutils.RenderTextureCartesian(c.renderer, "assets/textures/bg.bmp", "Q2", "Q4")
+
+	sdlutils.RenderTextureCartesian(c.renderer, "assets/textures/bg_overlay.bmp", "Q2", "Q4")
+
+	// Draw the title
+	sdlutils.DrawText(c.renderer, "Problems in "+c.configPath, sdl.Point{X: 25, Y: 25}, vars.Colors.WHITE, vars.HeaderFont)
+
+	action := "Running with the default settings until the file is fixed. A: continue, B: exit"
+	if !vars.HasErrors(c.issues) {
+		action = "A: continue with this config, B: exit"
+	}
+	sdlutils.DrawText(c.renderer, action, sdl.Point{X: 25, Y: 60}, vars.Colors.SECONDARY, vars.LongTextFont)
+
+	// Draw the text component with scrolling
+	c.textComponent.Draw(vars.Colors.WHITE)
+
+	sdlutils.RenderTextureCartesian(c.renderer, "assets/textures/$aspect_ratio/ui_controls.bmp", "Q3", "Q4")
+
+	c.renderer.Present()
+}
@@ -2,14 +2,15 @@ package screens
 
 import (
 	"context"
+	"fmt"
 	"handheldui/components"
 	"handheldui/helpers/sdlutils"
-	"handheldui/helpers/wrappers"
 	"handheldui/input"
 	"handheldui/output"
 	"handheldui/services"
 	"handheldui/vars"
 	"os"
+	"path"
 	"path/filepath"
 	"sort"
 	"strings"
@@ -23,27 +24,111 @@ type FilesScreen struct {
 	listComponent  *components.ListComponent
 	repoName       string
 	repoPath       string
+	items          []map[string]interface{}
+	fileTree       *services.FileTree
+	currentFolder  *services.FileTree
+	source         *services.ListingSource
+	manifest       services.Manifest
+	members        map[string]vars.CollectionDetails
+	memberLoads    chan memberListing
+	repoLoads      chan repositoryListing
+	hideInstalled  bool
+	pipeline       []vars.PipelineStep
+	collections    map[string]vars.CollectionDetails
 	progressBar    *components.ProgressBarComponent
 	isDownloading  bool
+	downloadLabel  string
+	downloadSource string
+	message        string
 	cancelDownload context.CancelFunc
+	infoScreen     *InfoScreen
 }
 
-func NewFilesScreen(renderer *sdl.Renderer) (*FilesScreen, error) {
-	listComponent := components.NewListComponent(
+// repositoryListing carries the files list of a repository read in the background, and
+// the items to download once it is shown
+type repositoryListing struct {
+	ctx         context.Context
+	repo        vars.PlatformDetails
+	manifest    services.Manifest
+	collections []vars.CollectionDetails
+	members     []vars.CollectionDetails
+	source      *services.ListingSource
+	items       []map[string]interface{}
+	err         error
+	queue       []map[string]interface{}
+}
+
+// memberListing carries the files of member collections read in the background
+type memberListing struct {
+	ctx      context.Context
+	tree     *services.FileTree
+	folder   *services.FileTree
+	members  []vars.CollectionDetails
+	items    []map[string]interface{}
+	download bool
+	err      error
+}
+
+var fileStatusLabels = map[string]string{
+	services.StatusNew:       "[NEW]",
+	services.StatusPresent:   "[OK]",
+	services.StatusDifferent: "[DIFF]",
+}
+
+func NewFilesScreen(renderer *sdl.Renderer, infoScreen *InfoScreen) (*FilesScreen, error) {
+	f := &FilesScreen{
+		renderer:    renderer,
+		infoScreen:  infoScreen,
+		memberLoads: make(chan memberListing, 1),
+		repoLoads:   make(chan repositoryListing, 1),
+	}
+
+	f.listComponent = components.NewListComponent(
 		renderer,
-		vars.Config.Screen.MaxListItens,
-		vars.Config.Screen.MaxListItemWidth,
+		vars.Config().Screen.MaxListItens,
+		vars.Config().Screen.MaxListItemWidth,
 		func(index int, item map[string]interface{}) string {
-			return item["name"].(string)
+			if folder, ok := item["folder"].(*services.FileTree); ok {
+				return fmt.Sprintf("[DIR] %s/", f.folderTitle(folder))
+			}
+			if multidisc, _ := item["multidisc"].(bool); multidisc {
+				return fmt.Sprintf("%s %s (%d discs)", fileStatusLabels[item["status"].(string)], path.Base(item["name"].(string)), len(item["discs"].([]string)))
+			}
+			if files, ok := item["set"].([]map[string]interface{}); ok {
+				return fmt.Sprintf("%s %s (+%d files)", fileStatusLabels[item["status"].(string)], path.Base(item["name"].(string)), len(files)-1)
+			}
+			return fmt.Sprintf("%s %s", fileStatusLabels[item["status"].(string)], path.Base(item["name"].(string)))
 		})
 
-	progressBar := components.NewProgressBarComponent(renderer, 300, 20, 490, 320, vars.Colors.WHITE, vars.Colors.SECONDARY)
+	f.progressBar = components.NewProgressBarComponent(renderer, 300, 20, 490, 320, vars.Colors.WHITE, vars.Colors.SECONDARY)
 
-	return &FilesScreen{
-		renderer:      renderer,
-		listComponent: listComponent,
-		progressBar:   progressBar,
-	}, nil
+	return f, nil
+}
+
+// folderTitle returns the name of a folder, the title of its collection when it holds one
+func (f *FilesScreen) folderTitle(folder *services.FileTree) string {
+	for _, collection := range f.collections {
+		if collection.Folder != "" && collection.Folder == folder.Path {
+			if title := services.CollectionTitle(collection.Name); title != collection.Name {
+				return title
+			}
+		}
+	}
+	return folder.Name
+}
+
+// headerTitle returns the repository title followed by the folders leading to the current one
+func (f *FilesScreen) headerTitle() string {
+	var names []string
+	for folder := f.currentFolder; folder != nil && folder.Path != ""; folder = folder.Parent {
+		names = append([]string{f.folderTitle(folder)}, names...)
+	}
+
+	title := repositoryTitle(vars.Config().Repositories[vars.CurrentRepo])
+	if len(names) > 0 {
+		title += " / " + strings.Join(names, " / ")
+	}
+	return title
 }
 
 func (f *FilesScreen) InitRepositories() {
@@ -51,62 +136,309 @@ func (f *FilesScreen) InitRepositories() {
 		return
 	}
 
-	if currentRepoDetails, ok := vars.Config.Repositories[vars.CurrentRepo]; ok {
-		f.repoName = currentRepoDetails.Name
-		f.repoPath = currentRepoDetails.Path
+	f.loadRepository()
+	f.initialized = true
+}
 
-		collections := currentRepoDetails.Collections
-		extList := currentRepoDetails.ExtList
+// loadRepository reads the collections and the files list of the current repository
+func (f *FilesScreen) loadRepository() {
+	if repo, ok := vars.Config().Repositories[vars.CurrentRepo]; ok {
+		f.showRepository(readRepository(vars.CurrentRepo, repo))
+	}
+}
 
-		// Initializes an items slice
-		var items []map[string]interface{}
+// readRepository reads the collections and the files list of a repository, without
+// touching the screen, so it can run in the background
+func readRepository(repoKey string, repo vars.PlatformDetails) repositoryListing {
+	listing := repositoryListing{repo: repo}
 
-		// Calls FetchAndSortAllMetadata to retrieve metadata from all repositories
-		for _, collection := range collections {
+	manifest, err := services.LoadManifest(repoKey)
+	if err != nil {
+		output.Errorf("Error loading installed files manifest: %v", err)
+		manifest = services.Manifest{}
+	}
+	listing.manifest = manifest
 
-			allMetadata, err := services.FetchMetadata(collection.Name)
-			if err != nil {
-				panic(output.Sprintf("Error fetching metadata: %v", err))
-			}
-
-			// Process the fetched metadata
-			for fileName, fileURL := range allMetadata {
-				// If extList is empty, add all files
-				if len(extList) == 0 {
-					items = append(items, map[string]interface{}{
-						"name":  fileName,
-						"value": fileURL,
-						"unzip": collection.Unzip,
-					})
-				} else {
-					// Check if the file has one of the specified extensions
-					for _, ext := range extList {
-						if strings.HasSuffix(fileName, ext) {
-							items = append(items, map[string]interface{}{
-								"name":  fileName,
-								"value": fileURL,
-								"unzip": collection.Unzip,
-							})
-							break
-						}
-					}
-				}
-			}
+	// Parent and favorites entries are expanded into their member items
+	collections, err := services.RepositoryCollections(repo, false)
+	if err != nil {
+		output.Errorf("Error expanding collections: %v", err)
+		collections = repo.Collections
+	}
+	listing.collections = collections
+
+	// Members are listed when their folder is opened
+	listed, members := services.SplitMembers(collections)
+	listing.members = members
+
+	// The listing indexes are scanned once, and huge listings are then read page by page
+	source, err := services.NewListingSource(repo, listed, manifest)
+	if err != nil {
+		output.Errorf("Error listing repository files: %v", err)
+		listing.err = err
+	} else if source.Len() > services.LargeListing {
+		listing.source = source
+	} else {
+		listing.items = source.Items(nil)
+	}
+
+	return listing
+}
+
+// showRepository lists the files of a repository, on the thread drawing the list
+func (f *FilesScreen) showRepository(listing repositoryListing) {
+	f.repoName = repositoryTitle(listing.repo)
+	f.repoPath = listing.repo.Path
+	f.hideInstalled = listing.repo.HideInstalled
+	f.pipeline = listing.repo.Pipeline
+	f.manifest = listing.manifest
+	f.source = listing.source
+	if listing.err != nil {
+		f.message = downloadErrorMessage(f.repoName, "", listing.err)
+	} else if listing.source != nil {
+		f.message = fmt.Sprintf("More than %d files, shown as a flat list without folders, disc sets, hiding installed files or renamed duplicates", services.LargeListing)
+	}
+
+	f.collections = make(map[string]vars.CollectionDetails)
+	for _, collection := range listing.collections {
+		f.collections[collection.Name] = collection
+	}
+
+	// Groups the items into folders, sorted by name
+	f.items = listing.items
+	f.fileTree = services.BuildFileTree(listing.items)
+	f.members = make(map[string]vars.CollectionDetails)
+	for _, member := range listing.members {
+		f.fileTree.Folder(member.Folder)
+		f.members[member.Folder] = member
+	}
+	f.currentFolder = f.fileTree
+
+	// Updates the list of items in the component
+	f.refreshList()
+}
+
+// pendingMembers returns the members inside the folder whose files are not listed yet
+func (f *FilesScreen) pendingMembers(folder *services.FileTree) []vars.CollectionDetails {
+	var members []vars.CollectionDetails
+	for folderPath, member := range f.members {
+		if folder.Path == "" || folderPath == folder.Path || strings.HasPrefix(folderPath, folder.Path+"/") {
+			members = append(members, member)
 		}
+	}
+	sort.Slice(members, func(i, j int) bool { return members[i].Folder < members[j].Folder })
+	return members
+}
 
-		// Sorts items before updating the list
-		sort.Slice(items, func(i, j int) bool {
-			return items[i]["name"].(string) < items[j]["name"].(string)
-		})
+// loadMembers reads the files of the members inside the folder in the background, then
+// opens the folder or downloads it. Draw adds the files to the list.
+func (f *FilesScreen) loadMembers(folder *services.FileTree, members []vars.CollectionDetails, download bool) {
+	ctx := f.startDownload("Reading the files list of " + f.folderTitle(folder))
+	repo := vars.Config().Repositories[vars.CurrentRepo]
+	manifest, listed, tree := f.manifest, f.items, f.fileTree
+
+	go func() {
+		items, err := services.ListCollectionFiles(repo, members, manifest, listed)
+		f.memberLoads <- memberListing{ctx: ctx, tree: tree, folder: folder, members: members, items: items, download: download, err: err}
+	}()
+}
+
+// addMembers lists the files of loaded members, on the thread drawing the list
+func (f *FilesScreen) addMembers(loaded memberListing) {
+	if loaded.ctx.Err() != nil || loaded.tree != f.fileTree {
+		return // Cancelled with B or read for a list shown before, the members stay pending
+	}
+	if loaded.err != nil {
+		output.Errorf("Error listing member files: %v", loaded.err)
+		f.message = downloadErrorMessage(f.folderTitle(loaded.folder), "", loaded.err)
+		f.finishDownload()
+		return
+	}
+
+	for _, member := range loaded.members {
+		delete(f.members, member.Folder)
+	}
+	f.items = append(f.items, loaded.items...)
+	f.fileTree.Add(loaded.items)
 
-		// Updates the list of items in the component
-		f.listComponent.SetItems(items)
+	if loaded.download {
+		go f.downloadFiles(loaded.ctx, loaded.folder.AllItems())
+		return
 	}
+	f.finishDownload()
+	f.openFolder(loaded.folder)
+}
 
+// downloadErrorMessage explains a failed request, pointing to the secrets file when the
+// credentials were refused
+func downloadErrorMessage(name, collection string, err error) string {
+	if services.IsCredentialWithheld(err, collection) {
+		return fmt.Sprintf("%s: credential not sent over http to %s, mark it insecure in %s to allow it", name, collection, vars.SecretsFilePath)
+	}
+	if services.IsAuthError(err) {
+		if collection == "" {
+			return fmt.Sprintf("%s: access denied, check the credentials in %s", name, vars.SecretsFilePath)
+		}
+		return fmt.Sprintf("%s: access denied to %s, check the credentials in %s", name, collection, vars.SecretsFilePath)
+	}
+	return fmt.Sprintf("%s: %v", name, err)
+}
+
+// QueueDownloads opens the files list of the current repository and downloads the given
+// items, as when they are selected one by one. The list is read in the background first,
+// and items are taken from it when it holds them, so their status is updated there.
+func (f *FilesScreen) QueueDownloads(items []map[string]interface{}) {
+	ctx := f.startDownload("Reading the files list")
 	f.initialized = true
+	vars.CurrentScreen = "files_screen"
+
+	repoKey, repo := vars.CurrentRepo, vars.Config().Repositories[vars.CurrentRepo]
+	go func() {
+		listing := readRepository(repoKey, repo)
+		listing.ctx, listing.queue = ctx, items
+		f.repoLoads <- listing
+	}()
+}
+
+// queueListed shows a repository read for QueueDownloads and downloads the queued items,
+// unless B cancelled them meanwhile
+func (f *FilesScreen) queueListed(listing repositoryListing) {
+	f.showRepository(listing)
+	if listing.ctx.Err() != nil {
+		return
+	}
+	go f.downloadFiles(listing.ctx, f.downloadQueue(listing.queue))
+}
+
+// downloadQueue returns the list items matching the given ones, the given ones being used
+// when the list doesn't hold them
+func (f *FilesScreen) downloadQueue(items []map[string]interface{}) []map[string]interface{} {
+	wanted := make(map[string]bool)
+	for _, item := range items {
+		wanted[item["name"].(string)] = true
+	}
+
+	var queue []map[string]interface{}
+	for _, item := range f.items {
+		if wanted[item["name"].(string)] {
+			queue = append(queue, item)
+			delete(wanted, item["name"].(string))
+		}
+	}
+
+	// Files inside disc sets, or hidden by the region filters, are downloaded as given
+	for _, item := range items {
+		if !wanted[item["name"].(string)] {
+			continue
+		}
+		if found := f.findItem(item["name"].(string)); found != nil {
+			item = found
+		}
+		queue = append(queue, item)
+	}
+
+	return queue
+}
+
+// refreshList updates the list component with the entries of the current folder
+func (f *FilesScreen) refreshList() {
+	var visibleItems []map[string]interface{}
+	for _, folder := range f.currentFolder.Folders {
+		status := f.folderStatus(folder)
+		if f.hideInstalled && status == services.StatusPresent {
+			continue
+		}
+		visibleItems = append(visibleItems, map[string]interface{}{
+			"name":   folder.Name,
+			"folder": folder,
+			"status": status,
+		})
+	}
+
+	// Member folders come before the files of a huge listing
+	if f.showsSource() {
+		f.listComponent.SetSource(foldersFirst{folders: visibleItems, source: f.source})
+		return
+	}
+
+	for _, item := range f.currentFolder.Items {
+		if f.hideInstalled && item["status"].(string) == services.StatusPresent {
+			continue
+		}
+		visibleItems = append(visibleItems, item)
+	}
+
+	f.listComponent.SetItems(visibleItems)
+}
+
+// showsSource checks if the current folder lists a huge listing page by page
+func (f *FilesScreen) showsSource() bool {
+	return f.source != nil && f.currentFolder == f.fileTree
+}
+
+// foldersFirst lists folder entries before the files of a ListingSource
+type foldersFirst struct {
+	folders []map[string]interface{}
+	source  components.ListSource
+}
+
+func (s foldersFirst) Len() int {
+	return len(s.folders) + s.source.Len()
+}
+
+func (s foldersFirst) Page(start, count int) []map[string]interface{} {
+	var page []map[string]interface{}
+	for i := start; i < len(s.folders) && len(page) < count; i++ {
+		page = append(page, s.folders[i])
+	}
+
+	if len(page) < count {
+		sourceStart := start - len(s.folders)
+		if sourceStart < 0 {
+			sourceStart = 0
+		}
+		page = append(page, s.source.Page(sourceStart, count-len(page))...)
+	}
+	return page
+}
+
+// folderStatus is present only when every file inside the folder is present. Folders
+// of members not listed yet count as new.
+func (f *FilesScreen) folderStatus(folder *services.FileTree) string {
+	if len(f.pendingMembers(folder)) > 0 {
+		return services.StatusNew
+	}
+
+	status := services.StatusPresent
+	for _, item := range folder.AllItems() {
+		switch item["status"].(string) {
+		case services.StatusNew:
+			return services.StatusNew
+		case services.StatusDifferent:
+			status = services.StatusDifferent
+		}
+	}
+	return status
+}
+
+// openFolder changes the current folder and lists its entries, reading the files of the
+// member shown by the folder first
+func (f *FilesScreen) openFolder(folder *services.FileTree) {
+	if member, ok := f.members[folder.Path]; ok {
+		f.loadMembers(folder, []vars.CollectionDetails{member}, false)
+		return
+	}
+
+	f.currentFolder = folder
+	f.refreshList()
 }
 
 func (f *FilesScreen) HandleInput(event input.InputEvent) {
+	// Any key dismisses the last download message
+	if !f.isDownloading {
+		f.message = ""
+	}
+
 	// Handle the B button regardless of the list state
 	if event.KeyCode == "B" {
 		if f.isDownloading {
@@ -116,15 +448,29 @@ func (f *FilesScreen) HandleInput(event input.InputEvent) {
 			f.isDownloading = false
 			f.progressBar.SetProgress(0.0)
 			f.cancelDownload = nil
+		} else if f.currentFolder != nil && f.currentFolder.Parent != nil {
+			f.openFolder(f.currentFolder.Parent)
 		} else {
 			f.initialized = false
-			vars.CurrentScreen = "repositories_screen"
+			vars.CurrentScreen = "actions_screen"
 		}
 		return
 	}
 
+	// Toggles installed files visibility even when everything is hidden
+	if event.KeyCode == "Y" && !f.isDownloading {
+		if f.showsSource() {
+			f.message = fmt.Sprintf("Flat lists of more than %d files can't hide installed files", services.LargeListing)
+			return
+		}
+		f.hideInstalled = !f.hideInstalled
+		f.refreshList()
+		return
+	}
+
 	// Skip other input handling if the list is empty
-	if len(f.listComponent.GetItems()) == 0 {
+	selectedItem := f.listComponent.GetSelectedItem()
+	if selectedItem == nil {
 		return
 	}
 
@@ -139,14 +485,41 @@ func (f *FilesScreen) HandleInput(event input.InputEvent) {
 	case "R1":
 		f.listComponent.PageDown()
 	case "A":
-		selectedItem := f.listComponent.GetItems()[f.listComponent.GetSelectedIndex()]
-		go f.downloadFile(f.repoPath, selectedItem)
+		if folder, ok := selectedItem["folder"].(*services.FileTree); ok {
+			f.openFolder(folder)
+			return
+		}
+		go f.downloadFiles(f.startDownload(""), []map[string]interface{}{selectedItem})
+	case "X":
+		// Downloads the selected folder with all of its subfolders
+		if folder, ok := selectedItem["folder"].(*services.FileTree); ok {
+			if members := f.pendingMembers(folder); len(members) > 0 {
+				f.loadMembers(folder, members, true)
+				return
+			}
+			go f.downloadFiles(f.startDownload(""), folder.AllItems())
+			return
+		}
+
+		// Shows the collection of the selected file
+		if collection, ok := selectedItem["collection"].(string); ok {
+			f.infoScreen.Show(services.CollectionTitle(collection), []string{collection}, "files_screen")
+		}
 	}
 }
 
 func (f *FilesScreen) Draw() {
 	f.InitRepositories()
 
+	// Lists the files read in the background
+	select {
+	case listing := <-f.repoLoads:
+		f.queueListed(listing)
+	case loaded := <-f.memberLoads:
+		f.addMembers(loaded)
+	default:
+	}
+
 	f.renderer.SetDrawColor(255, 255, 255, 255)
 	f.renderer.Clear()
 
@@ -158,6 +531,13 @@ func (f *FilesScreen) Draw() {
 
 		f.progressBar.Draw()
 
+		sdlutils.DrawText(f.renderer, f.downloadLabel, sdl.Point{X: 25, Y: 25}, vars.Colors.WHITE, vars.HeaderFont)
+
+		// Shows the mirror serving the current file
+		if f.downloadSource != "" {
+			sdlutils.DrawText(f.renderer, "From: "+f.downloadSource, sdl.Point{X: 25, Y: 60}, vars.Colors.SECONDARY, vars.LongTextFont)
+		}
+
 		sdlutils.RenderTextureCartesian(f.renderer, "assets/textures/$aspect_ratio/ui_controls_download.bmp", "Q3", "Q4")
 
 	} else {
@@ -166,7 +546,25 @@ func (f *FilesScreen) Draw() {
 		sdlutils.RenderTextureCartesian(f.renderer, "assets/textures/bg.bmp", "Q2", "Q4")
 
 		// Draws the current title
-		sdlutils.DrawText(f.renderer, f.repoName, sdl.Point{X: 25, Y: 25}, vars.Colors.WHITE, vars.HeaderFont)
+		title := f.headerTitle()
+		if f.showsSource() {
+			title += " (flat list)"
+		} else if f.hideInstalled {
+			title += " (hiding installed)"
+		}
+		sdlutils.DrawText(f.renderer, title, sdl.Point{X: 25, Y: 25}, vars.Colors.WHITE, vars.HeaderFont)
+
+		// Draws the last download error or where the selected file will be saved
+		if f.message != "" {
+			sdlutils.DrawText(f.renderer, f.message, sdl.Point{X: 25, Y: 60}, vars.Colors.WHITE, vars.LongTextFont)
+		} else if selectedItem := f.listComponent.GetSelectedItem(); selectedItem != nil {
+			if destPath, ok := selectedItem["path"].(string); ok {
+				if _, isSet := selectedItem["set"]; !isSet {
+					destPath = filepath.Join(destPath, selectedItem["localname"].(string))
+				}
+				sdlutils.DrawText(f.renderer, "Saves to: "+destPath, sdl.Point{X: 25, Y: 60}, vars.Colors.SECONDARY, vars.LongTextFont)
+			}
+		}
 
 		// Draws the list component
 		f.listComponent.Draw(vars.Colors.SECONDARY, vars.Colors.WHITE)
@@ -177,63 +575,229 @@ func (f *FilesScreen) Draw() {
 	f.renderer.Present()
 }
 
-func (f *FilesScreen) downloadFile(path string, selectedItem map[string]interface{}) {
-	// get variables
-	uri := selectedItem["value"].(string)
-	fileName := selectedItem["name"].(string)
-	unzip := selectedItem["unzip"].(bool)
-
-	// Creates a context to cancel the download
+// startDownload shows the progress screen and returns the context cancelled by B
+func (f *FilesScreen) startDownload(label string) context.Context {
 	ctx, cancel := context.WithCancel(context.Background())
 	f.cancelDownload = cancel
-
+	f.downloadLabel = label
 	f.progressBar.SetProgress(0.0)
 	f.isDownloading = true
+	return ctx
+}
 
-	// Download file
-	err := services.DownloadFile(ctx, path, fileName, uri, func(downloaded, total int64) {
-		// Update progress
-		f.progressBar.SetProgress(float64(downloaded) / float64(total) * 100)
-		if f.progressBar.GetProgress() >= 100 {
-			f.isDownloading = false
-			if unzip {
-				splitedPath := strings.Split(fileName, "/")
-				deepSize := len(splitedPath) - 1
-				f.unzipFile(path, splitedPath[deepSize])
+// finishDownload releases the download context and goes back to the list
+func (f *FilesScreen) finishDownload() {
+	if f.cancelDownload != nil {
+		f.cancelDownload()
+	}
+	f.isDownloading = false
+	f.cancelDownload = nil
+	f.downloadLabel = ""
+}
+
+// downloadFiles downloads the items one after another, each into its own destination
+func (f *FilesScreen) downloadFiles(ctx context.Context, items []map[string]interface{}) {
+	// Disc sets are downloaded as a whole
+	total := 0
+	for _, item := range items {
+		total += len(services.DiscSetFiles(item))
+	}
+
+	count := 0
+	installedCount := 0
+	for _, item := range items {
+		installedFiles := make(map[string]services.InstalledFile)
+
+		for _, file := range services.DiscSetFiles(item) {
+			if ctx.Err() != nil {
+				break
 			}
+
+			count++
+			f.downloadLabel = fmt.Sprintf("Downloading %d of %d: %s", count, total, path.Base(file["name"].(string)))
+
+			installed, err := f.downloadFile(ctx, file["path"].(string), file)
+			if err != nil {
+				output.Errorf("Error during download: %v", err)
+				f.message = downloadErrorMessage(path.Base(file["name"].(string)), file["collection"].(string), err)
+				continue
+			}
+			installedFiles[file["name"].(string)] = installed
+			installedCount++
 		}
-	})
 
-	if err != nil {
-		output.Errorf("Error during download", err)
-		f.isDownloading = false
-		f.cancelDownload = nil
-		return
+		if ctx.Err() != nil {
+			break
+		}
+
+		if _, ok := item["set"]; ok {
+			f.completeDiscSet(ctx, item, installedFiles)
+		}
+	}
+
+	// Rebuilds the frontend lists marked to follow the downloads, unless they were
+	// cancelled or nothing was installed
+	if ctx.Err() == nil && installedCount > 0 {
+		f.downloadLabel = "Exporting lists"
+		if err := services.ExportLists(vars.CurrentRepo, vars.Config().Repositories[vars.CurrentRepo], true); err != nil {
+			output.Errorf("Error exporting lists: %v", err)
+		}
+	}
+
+	f.finishDownload()
+
+	// Installed files must disappear from the list when they are hidden
+	if f.hideInstalled {
+		f.refreshList()
 	}
 }
 
-func (f *FilesScreen) unzipFile(path string, fileName string) {
-	// Caminho completo do arquivo ZIP
-	zipFilePath := filepath.Join(path, fileName)
+// completeDiscSet downloads the tracks referenced by the cue sheets of a set that were
+// not listed with it, and writes the .m3u playlist of multi-disc games.
+func (f *FilesScreen) completeDiscSet(ctx context.Context, set map[string]interface{}, installedFiles map[string]services.InstalledFile) {
+	for name, installed := range installedFiles {
+		if !strings.EqualFold(filepath.Ext(installed.Path), ".cue") {
+			continue
+		}
 
-	// Destino da extração (diretório atual sem subpastas adicionais)
-	destDir := path
+		references, err := services.ReadCueFiles(installed.Path)
+		if err != nil {
+			output.Errorf("Error reading cue sheet %s: %v", installed.Path, err)
+			continue
+		}
 
-	// Executa a descompactação em uma goroutine
-	go func() {
-		// Chama a função de descompactação com os caminhos corretos
-		err := wrappers.UnzipFile(zipFilePath, destDir)
+		for _, reference := range references {
+			if _, err := os.Stat(filepath.Join(filepath.Dir(installed.Path), reference)); err == nil {
+				continue
+			}
+
+			track := f.findItem(path.Join(path.Dir(name), reference))
+			if track == nil {
+				output.Errorf("Track %s referenced by %s not found in the collection", reference, name)
+				continue
+			}
+
+			f.downloadLabel = fmt.Sprintf("Downloading track: %s", reference)
+			if _, err := f.downloadFile(ctx, filepath.Dir(installed.Path), track); err != nil {
+				output.Errorf("Error during download: %v", err)
+			}
+		}
+	}
+
+	if multidisc, _ := set["multidisc"].(bool); multidisc {
+		var discs []string
+		var destPath string
+		for _, disc := range set["discs"].([]string) {
+			installed, ok := installedFiles[disc]
+			if !ok {
+				output.Errorf("Disc %s was not downloaded, skipping playlist", disc)
+				return
+			}
+			if destPath == "" {
+				destPath = filepath.Dir(installed.Path)
+			}
+			discs = append(discs, services.PlayableDiscFile(installed))
+		}
+
+		playlist, err := services.WritePlaylist(destPath, path.Base(set["name"].(string)), discs)
 		if err != nil {
-			output.Printf("Error extracting file: %v\n", err)
-			return
+			output.Errorf("Error writing playlist: %v", err)
+		} else if artwork, ok := vars.Config().Artwork[vars.CurrentPlatform()]; ok {
+			// Frontends list the playlist instead of the discs, so it needs its own image
+			if _, err := services.ExportArtwork(playlist, vars.Config().Repositories[vars.CurrentRepo].System, artwork); err != nil {
+				output.Errorf("Error exporting artwork: %v", err)
+			}
+		}
+	}
+
+	set["status"] = services.DiscSetStatus(set)
+}
+
+// findItem looks for a file of the repository by its full name
+func (f *FilesScreen) findItem(name string) map[string]interface{} {
+	for _, item := range f.items {
+		for _, file := range services.DiscSetFiles(item) {
+			if file["name"].(string) == name {
+				return file
+			}
 		}
+	}
+
+	if f.source != nil {
+		return f.source.Find(name)
+	}
+	return nil
+}
+
+func (f *FilesScreen) downloadFile(ctx context.Context, destPath string, selectedItem map[string]interface{}) (services.InstalledFile, error) {
+	// get variables
+	localName := selectedItem["localname"].(string)
+	unzip := selectedItem["unzip"].(bool)
+	collection := selectedItem["collection"].(string)
+	file := selectedItem["file"].(services.File)
+
+	f.progressBar.SetProgress(0.0)
 
-		// Remove o arquivo ZIP após a extração
-		err = os.Remove(zipFilePath)
+	// Download file, already saved under its normalized name
+	var source string
+	err := services.DownloadCollectionFile(ctx, f.collections[collection], destPath, localName, file, func(downloaded, total int64) {
+		// Update progress
+		f.progressBar.SetProgress(float64(downloaded) / float64(total) * 100)
+	}, func(mirror string) {
+		source = services.MirrorName(mirror)
+		f.downloadSource = source
+	})
+	f.downloadSource = ""
+	if err != nil {
+		return services.InstalledFile{}, err
+	}
+
+	installed := services.InstalledFile{
+		Name:       file.Name,
+		Collection: collection,
+		Path:       filepath.Join(destPath, localName),
+		Size:       file.Size,
+		MD5:        file.MD5,
+		Source:     source,
+	}
+
+	// Runs the post-download steps, extracting zip files by default
+	steps := f.pipeline
+	if len(steps) == 0 {
+		steps = services.DefaultPipeline(unzip)
+	}
+
+	err = services.RunPipeline(ctx, steps, &installed, file, func(step string) {
+		f.downloadLabel = fmt.Sprintf("Running %s: %s", step, localName)
+	})
+	if err != nil {
+		return installed, err
+	}
+
+	// Applies the collection patches, writing the patched ROMs as new files
+	if collectionDetails := f.collections[collection]; len(collectionDetails.Patches) > 0 {
+		installed.Patched, err = services.ApplyPatches(ctx, collectionDetails, installed, func(status string) {
+			f.downloadLabel = status
+		})
 		if err != nil {
-			output.Printf("Error removing zip file: %v\n", err)
-		} else {
-			output.Printf("Successfully removed zip file: %s\n", zipFilePath)
+			output.Errorf("Error applying patches: %v", err)
+			f.message = fmt.Sprintf("%s: %v", localName, err)
 		}
-	}()
+	}
+
+	// Writes the cover of the game into the image folder of the frontend
+	if artwork, ok := vars.Config().Artwork[vars.CurrentPlatform()]; ok {
+		f.downloadLabel = fmt.Sprintf("Exporting artwork: %s", localName)
+		if err := services.ExportInstalledArtwork(installed, vars.Config().Repositories[vars.CurrentRepo], artwork); err != nil {
+			output.Errorf("Error exporting artwork: %v", err)
+		}
+	}
+
+	if err := services.RecordInstall(vars.CurrentRepo, installed); err != nil {
+		output.Errorf("Error recording installed file: %v", err)
+	}
+
+	selectedItem["status"] = services.StatusPresent
+
+	return installed, nil
 }
@@ -29,8 +29,8 @@ type GamesScreen struct {
 func NewGamesScreen(renderer *sdl.Renderer) (*GamesScreen, error) {
 	listComponent := components.NewListComponent(
 		renderer,
-		vars.Config.Screen.MaxListItens,
-		vars.Config.Screen.MaxListItemWidth/2,
+		vars.Config().Screen.MaxListItens,
+		vars.Config().Screen.MaxListItemWidth/2,
 		func(index int, item map[string]interface{}) string {
 			return fmt.Sprintf("%d. %s", index+1, item["name"].(string))
 		})
@@ -48,7 +48,7 @@ func (g *GamesScreen) InitGames() {
 		return
 	}
 
-	games, err := services.FetchGames(vars.CurrentPlatform, vars.CurrentSystem)
+	games, err := services.FetchGames(vars.CurrentPlatform(), vars.CurrentSystem)
 	if err != nil {
 		output.Errorf("Error fetching games: %v\n", err)
 		return
@@ -19,8 +19,8 @@ type HomeScreen struct {
 func NewHomeScreen(renderer *sdl.Renderer) (*HomeScreen, error) {
 	listComponent := components.NewListComponent(
 		renderer,
-		vars.Config.Screen.MaxListItens,
-		vars.Config.Screen.MaxListItemWidth,
+		vars.Config().Screen.MaxListItens,
+		vars.Config().Screen.MaxListItemWidth,
 		func(index int, item map[string]interface{}) string {
 			return item["label"].(string)
 		})
@@ -39,6 +39,8 @@ func (h *HomeScreen) InitHome() {
 	buttons := []map[string]interface{}{
 		{"label": "Reviews", "action": func() { vars.CurrentScreen = "systems_screen" }},
 		{"label": "Repositories", "action": func() { vars.CurrentScreen = "repositories_screen" }},
+		{"label": "Search", "action": func() { vars.CurrentScreen = "search_screen" }},
+		{"label": "Settings", "action": func() { vars.CurrentScreen = "settings_screen" }},
 	}
 
 	h.listComponent.SetItems(buttons)
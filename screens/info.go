@@ -0,0 +1,138 @@
+package screens
+
+import (
+	"fmt"
+	"handheldui/components"
+	"handheldui/helpers/sdlutils"
+	"handheldui/input"
+	"handheldui/output"
+	"handheldui/services"
+	"handheldui/vars"
+	"strings"
+
+	"github.com/veandco/go-sdl2/sdl"
+)
+
+type InfoScreen struct {
+	renderer      *sdl.Renderer
+	textComponent *components.TextComponent
+	title         string
+	collections   []string
+	backScreen    string
+	initialized   bool
+	// loaded receives the text of the page, read in the background
+	loaded chan string
+}
+
+func NewInfoScreen(renderer *sdl.Renderer) (*InfoScreen, error) {
+	return &InfoScreen{
+		renderer: renderer,
+	}, nil
+}
+
+// Show opens the info page of the collections, returning to backScreen on B
+func (i *InfoScreen) Show(title string, collections []string, backScreen string) {
+	i.title = title
+	i.collections = collections
+	i.backScreen = backScreen
+	i.initialized = false
+	vars.CurrentScreen = "info_screen"
+}
+
+func (i *InfoScreen) InitInfo() {
+	if i.initialized {
+		return
+	}
+
+	i.setText("Loading...")
+
+	// The metadata is downloaded in the background, the page showing once it is read
+	loaded := make(chan string, 1)
+	i.loaded = loaded
+	go func(collections []string) {
+		var sections []string
+		for _, collection := range collections {
+			metadata, err := services.FetchItemMetadata(collection)
+			if err != nil {
+				output.Errorf("Error fetching item metadata of %s: %v", collection, err)
+			}
+			sections = append(sections, collectionInfo(metadata))
+		}
+
+		text := strings.Join(sections, "\n\n")
+		if text == "" {
+			text = "No collections"
+		}
+		loaded <- text
+	}(i.collections)
+
+	i.initialized = true
+}
+
+func (i *InfoScreen) setText(text string) {
+	i.textComponent = components.NewTextComponent(i.renderer, text, vars.LongTextFont, vars.Config().Screen.MaxLines, int(vars.Config().Screen.Width)-20)
+}
+
+// collectionInfo lists the known fields of a collection
+func collectionInfo(metadata services.ItemMetadata) string {
+	lines := []string{metadata.DisplayTitle()}
+	if metadata.Title != "" {
+		lines = append(lines, fmt.Sprintf("Identifier: %s", metadata.Identifier))
+	}
+	if len(metadata.Creator) > 0 {
+		lines = append(lines, fmt.Sprintf("Creator: %s", strings.Join(metadata.Creator, ", ")))
+	}
+	if metadata.Date != "" {
+		lines = append(lines, fmt.Sprintf("Date: %s", metadata.Date))
+	}
+	if license := metadata.License(); license != "" {
+		lines = append(lines, fmt.Sprintf("License: %s", license))
+	}
+	if description := metadata.PlainDescription(); description != "" {
+		lines = append(lines, description)
+	}
+	return strings.Join(lines, "\n")
+}
+
+func (i *InfoScreen) HandleInput(event input.InputEvent) {
+	switch event.KeyCode {
+	case "DOWN":
+		i.textComponent.ScrollDown()
+	case "UP":
+		i.textComponent.ScrollUp()
+	case "B":
+		i.initialized = false
+		vars.CurrentScreen = i.backScreen
+	}
+}
+
+func (i *InfoScreen) Draw() {
+	i.InitInfo()
+
+	select {
+	case text := <-i.loaded:
+		i.setText(text)
+		// A title shown as an identifier is known once the metadata is read
+		if len(i.collections) == 1 && i.title == i.collections[0] {
+			i.title = services.CollectionTitle(i.title)
+		}
+	default:
+	}
+
+	i.renderer.SetDrawColor(0, 0, 0, 255) // Background color
+	i.renderer.Clear()
+
+	sdlutils.RenderTextureCartesian(i.renderer, "assets/textures/bg.bmp", "Q2", "Q4")
+
+	sdlutils.RenderTextureCartesian(i.renderer, "assets/textures/bg_overlay.bmp", "Q2", "Q4")
+
+	// Draw the title
+	sdlutils.DrawText(i.renderer, i.title, sdl.Point{X: 25, Y: 25}, vars.Colors.WHITE, vars.HeaderFont)
+
+	// Draw the text component with scrolling
+	i.textComponent.Draw(vars.Colors.WHITE)
+
+	sdlutils.RenderTextureCartesian(i.renderer, "assets/textures/$aspect_ratio/ui_controls.bmp", "Q3", "Q4")
+
+	i.renderer.Present()
+}
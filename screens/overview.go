@@ -35,7 +35,7 @@ func (o *OverviewScreen) InitOverview() {
 		overview = "Help us to find an overview!"
 	}
 
-	review, err := services.FetchGameMarkdown(vars.CurrentPlatform, vars.CurrentSystem, vars.CurrentGame, vars.CurrentTester)
+	review, err := services.FetchGameMarkdown(vars.CurrentPlatform(), vars.CurrentSystem, vars.CurrentGame, vars.CurrentTester)
 	if err != nil {
 		review = "Oops, game description not found!"
 	}
@@ -44,7 +44,7 @@ func (o *OverviewScreen) InitOverview() {
 	plainOverview := markdown.MarkdownToPlaintext(overview)
 
 	o.textContent = strings.ReplaceAll(plainReview, "%game_overview%", plainOverview)
-	o.textComponent = components.NewTextComponent(o.renderer, o.textContent, vars.LongTextFont, vars.Config.Screen.MaxLines, int(vars.Config.Screen.Width)-20)
+	o.textComponent = components.NewTextComponent(o.renderer, o.textContent, vars.LongTextFont, vars.Config().Screen.MaxLines, int(vars.Config().Screen.Width)-20)
 
 	o.initialized = true
 }
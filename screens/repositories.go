@@ -4,7 +4,9 @@ import (
 	"handheldui/components"
 	"handheldui/helpers/sdlutils"
 	"handheldui/input"
+	"handheldui/services"
 	"handheldui/vars"
+	"strings"
 
 	"github.com/veandco/go-sdl2/sdl"
 )
@@ -13,20 +15,23 @@ type RepositoriesScreen struct {
 	initialized   bool
 	renderer      *sdl.Renderer
 	listComponent *components.ListComponent
+	infoScreen    *InfoScreen
 }
 
-func NewRepositoriesScreen(renderer *sdl.Renderer) (*RepositoriesScreen, error) {
+func NewRepositoriesScreen(renderer *sdl.Renderer, infoScreen *InfoScreen) (*RepositoriesScreen, error) {
 	listComponent := components.NewListComponent(
 		renderer,
-		vars.Config.Screen.MaxListItens,
-		vars.Config.Screen.MaxListItemWidth,
+		vars.Config().Screen.MaxListItens,
+		vars.Config().Screen.MaxListItemWidth,
 		func(index int, item map[string]interface{}) string {
-			return item["name"].(string)
+			// Titles fetched in the background show up once they arrive
+			return repositoryTitle(vars.Config().Repositories[item["value"].(string)])
 		})
 
 	return &RepositoriesScreen{
 		renderer:      renderer,
 		listComponent: listComponent,
+		infoScreen:    infoScreen,
 	}, nil
 }
 
@@ -35,13 +40,13 @@ func (r *RepositoriesScreen) InitRepositories() {
 		return
 	}
 
-	repositories := vars.Config.Repositories
+	repositories := vars.Config().Repositories
 
 	var items []map[string]interface{}
 
 	for innerKey, repo := range repositories {
 		items = append(items, map[string]interface{}{
-			"name":  repo.Name,
+			"name":  repositoryTitle(repo),
 			"value": innerKey,
 		})
 	}
@@ -51,6 +56,28 @@ func (r *RepositoriesScreen) InitRepositories() {
 	r.initialized = true
 }
 
+// repositoryTitle returns the configured name of a repository, or the title of its first
+// collection when it has no name. It doesn't wait for titles not downloaded yet.
+func repositoryTitle(repo vars.PlatformDetails) string {
+	if repo.Name != "" || len(repo.Collections) == 0 {
+		return repo.Name
+	}
+	return services.CollectionTitle(repo.Collections[0].Name)
+}
+
+// collectionNames returns the identifiers of the collections of a repository
+func collectionNames(repo vars.PlatformDetails) []string {
+	var names []string
+	for _, collection := range repo.Collections {
+		if collection.Type == services.CollectionFavorites && !strings.HasPrefix(collection.Name, "fav-") {
+			names = append(names, "fav-"+collection.Name)
+			continue
+		}
+		names = append(names, collection.Name)
+	}
+	return names
+}
+
 func (r *RepositoriesScreen) HandleInput(event input.InputEvent) {
 	if len(r.listComponent.GetItems()) == 0 {
 		return
@@ -68,7 +95,12 @@ func (r *RepositoriesScreen) HandleInput(event input.InputEvent) {
 	case "A":
 		selectedItem := r.listComponent.GetItems()[r.listComponent.GetSelectedIndex()]
 		vars.CurrentRepo = selectedItem["value"].(string)
-		vars.CurrentScreen = "files_screen"
+		vars.CurrentScreen = "actions_screen"
+	case "X":
+		// Shows the title, description and license of the collections
+		selectedItem := r.listComponent.GetItems()[r.listComponent.GetSelectedIndex()]
+		repo := vars.Config().Repositories[selectedItem["value"].(string)]
+		r.infoScreen.Show(repositoryTitle(repo), collectionNames(repo), "repositories_screen")
 	case "B":
 		vars.CurrentScreen = "home_screen"
 	}
@@ -22,8 +22,8 @@ type ReviewsScreen struct {
 func NewReviewsScreen(renderer *sdl.Renderer) (*ReviewsScreen, error) {
 	listComponent := components.NewListComponent(
 		renderer,
-		vars.Config.Screen.MaxListItens,
-		vars.Config.Screen.MaxListItemWidth,
+		vars.Config().Screen.MaxListItens,
+		vars.Config().Screen.MaxListItemWidth,
 		func(index int, item map[string]interface{}) string {
 			return fmt.Sprintf("%d. %s", index+1, item["name"].(string))
 		})
@@ -41,7 +41,7 @@ func (r *ReviewsScreen) InitReviews() {
 		return
 	}
 
-	testers, err := services.FetchTesters(vars.CurrentPlatform, vars.CurrentSystem, vars.CurrentGame)
+	testers, err := services.FetchTesters(vars.CurrentPlatform(), vars.CurrentSystem, vars.CurrentGame)
 	if err != nil {
 		output.Errorf("Error fetching games: %v\n", err)
 		return
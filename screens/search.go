@@ -0,0 +1,194 @@
+package screens
+
+import (
+	"fmt"
+	"handheldui/components"
+	"handheldui/helpers/sdlutils"
+	"handheldui/input"
+	"handheldui/services"
+	"handheldui/vars"
+	"path"
+	"path/filepath"
+
+	"github.com/veandco/go-sdl2/sdl"
+)
+
+type SearchScreen struct {
+	initialized   bool
+	renderer      *sdl.Renderer
+	listComponent *components.ListComponent
+	keyboard      *components.KeyboardComponent
+	filesScreen   *FilesScreen
+	catalog       *services.SearchCatalog
+	loaded        chan *services.SearchCatalog
+	typing        bool
+	isLoading     bool
+}
+
+func NewSearchScreen(renderer *sdl.Renderer, filesScreen *FilesScreen) (*SearchScreen, error) {
+	listComponent := components.NewListComponent(
+		renderer,
+		vars.Config().Screen.MaxListItens,
+		vars.Config().Screen.MaxListItemWidth,
+		func(index int, item map[string]interface{}) string {
+			file := item["item"].(map[string]interface{})
+			return fmt.Sprintf("%s %s (%s / %s)", fileStatusLabels[file["status"].(string)], path.Base(file["name"].(string)), item["reponame"].(string), file["collection"].(string))
+		})
+
+	return &SearchScreen{
+		renderer:      renderer,
+		listComponent: listComponent,
+		keyboard:      components.NewKeyboardComponent(renderer, 40, 150),
+		filesScreen:   filesScreen,
+		loaded:        make(chan *services.SearchCatalog, 1),
+	}, nil
+}
+
+func (s *SearchScreen) InitSearch() {
+	if s.initialized {
+		return
+	}
+
+	s.typing = true
+	s.listComponent.SetItems(nil)
+
+	// The catalog is read again on every visit, as downloads and syncs change it
+	select {
+	case <-s.loaded: // Drops a catalog read for a previous visit
+	default:
+	}
+	s.isLoading = true
+	go func() { s.loaded <- services.LoadSearchCatalog() }()
+
+	s.initialized = true
+}
+
+// search lists the files matching the typed text
+func (s *SearchScreen) search() {
+	if s.catalog == nil {
+		return
+	}
+
+	var items []map[string]interface{}
+	for _, result := range s.catalog.Search(s.keyboard.GetText()) {
+		items = append(items, map[string]interface{}{
+			"item":     result.Item,
+			"repo":     result.RepoKey,
+			"reponame": result.RepoName,
+		})
+	}
+
+	s.listComponent.SetItems(items)
+}
+
+func (s *SearchScreen) HandleInput(event input.InputEvent) {
+	if s.isLoading {
+		if event.KeyCode == "B" {
+			s.initialized = false
+			vars.CurrentScreen = "home_screen"
+		}
+		return
+	}
+
+	if s.typing {
+		switch event.KeyCode {
+		case "UP":
+			s.keyboard.MoveUp()
+		case "DOWN":
+			s.keyboard.MoveDown()
+		case "LEFT", "L1":
+			s.keyboard.MoveLeft()
+		case "RIGHT", "R1":
+			s.keyboard.MoveRight()
+		case "A":
+			s.keyboard.Press()
+			s.search()
+		case "Y":
+			s.keyboard.Delete()
+			s.search()
+		case "X":
+			// Moves to the results
+			if len(s.listComponent.GetItems()) > 0 {
+				s.typing = false
+			}
+		case "B":
+			s.initialized = false
+			vars.CurrentScreen = "home_screen"
+		}
+		return
+	}
+
+	switch event.KeyCode {
+	case "DOWN":
+		s.listComponent.ScrollDown()
+	case "UP":
+		s.listComponent.ScrollUp()
+	case "L1":
+		s.listComponent.PageUp()
+	case "R1":
+		s.listComponent.PageDown()
+	case "A":
+		// Downloads the file from the files list of its repository
+		selectedItem := s.listComponent.GetSelectedItem()
+		if selectedItem == nil {
+			return
+		}
+		vars.CurrentRepo = selectedItem["repo"].(string)
+		s.initialized = false
+		s.filesScreen.QueueDownloads([]map[string]interface{}{selectedItem["item"].(map[string]interface{})})
+	case "X", "B":
+		s.typing = true
+	}
+}
+
+func (s *SearchScreen) Draw() {
+	s.InitSearch()
+
+	// Lists the catalog read in the background
+	select {
+	case catalog := <-s.loaded:
+		s.catalog = catalog
+		s.isLoading = false
+		s.search()
+	default:
+	}
+
+	s.renderer.SetDrawColor(255, 255, 255, 255)
+	s.renderer.Clear()
+
+	sdlutils.RenderTextureCartesian(s.renderer, "assets/textures/bg.bmp", "Q2", "Q4")
+
+	sdlutils.DrawText(s.renderer, "Search: "+s.keyboard.GetText()+"_", sdl.Point{X: 25, Y: 25}, vars.Colors.WHITE, vars.HeaderFont)
+
+	if s.isLoading {
+		sdlutils.DrawText(s.renderer, "Reading the repository listings...", sdl.Point{X: 25, Y: 60}, vars.Colors.WHITE, vars.LongTextFont)
+	} else if s.typing {
+		// Shows how many files match while typing
+		message := fmt.Sprintf("%d files. A types, Y deletes, X shows the results", s.catalog.Len())
+		if s.keyboard.GetText() != "" {
+			message = fmt.Sprintf("%d results. A types, Y deletes, X shows the results", len(s.listComponent.GetItems()))
+		}
+		sdlutils.DrawText(s.renderer, message, sdl.Point{X: 25, Y: 60}, vars.Colors.SECONDARY, vars.LongTextFont)
+
+		// Collections never opened or synced have no listing to search
+		if missing := len(s.catalog.Missing()); missing > 0 {
+			notice := fmt.Sprintf("%d collections not searched, open or sync their repository to download their listing", missing)
+			sdlutils.DrawText(s.renderer, notice, sdl.Point{X: 25, Y: 95}, vars.Colors.SECONDARY, vars.LongTextFont)
+		}
+
+		s.keyboard.Draw(vars.Colors.SECONDARY, vars.Colors.WHITE)
+	} else {
+		// Draws where the selected file will be saved
+		if selectedItem := s.listComponent.GetSelectedItem(); selectedItem != nil {
+			file := selectedItem["item"].(map[string]interface{})
+			destPath := filepath.Join(file["path"].(string), file["localname"].(string))
+			sdlutils.DrawText(s.renderer, "Saves to: "+destPath, sdl.Point{X: 25, Y: 60}, vars.Colors.SECONDARY, vars.LongTextFont)
+		}
+
+		s.listComponent.Draw(vars.Colors.SECONDARY, vars.Colors.WHITE)
+	}
+
+	sdlutils.RenderTextureCartesian(s.renderer, "assets/textures/$aspect_ratio/ui_controls.bmp", "Q3", "Q4")
+
+	s.renderer.Present()
+}
@@ -0,0 +1,507 @@
+package screens
+
+import (
+	"errors"
+	"fmt"
+	"handheldui/components"
+	"handheldui/helpers/sdlutils"
+	"handheldui/input"
+	"handheldui/output"
+	"handheldui/services"
+	"handheldui/vars"
+	"sort"
+
+	"github.com/veandco/go-sdl2/sdl"
+)
+
+// BackgroundMusic plays while the app runs, unless it's turned off in the settings
+const BackgroundMusic = "assets/sounds/Retro_Mystic.ogg"
+
+// screenSizes are the resolutions offered in the settings, besides the configured one
+var screenSizes = [][2]int32{{1280, 720}, {1024, 768}, {640, 480}, {720, 720}, {1920, 1080}}
+
+var controlTypes = []string{"joystick", "keyboard"}
+
+type repositorySettings struct {
+	Path          string
+	HideInstalled bool
+}
+
+// settingsValues are the values the settings screen edits
+type settingsValues struct {
+	Logs         bool
+	Control      string
+	Width        int32
+	Height       int32
+	Volume       int
+	Music        bool
+	Platform     string
+	Repositories map[string]repositorySettings
+}
+
+func (v settingsValues) clone() settingsValues {
+	repositories := make(map[string]repositorySettings, len(v.Repositories))
+	for key, repo := range v.Repositories {
+		repositories[key] = repo
+	}
+	v.Repositories = repositories
+	return v
+}
+
+type SettingsScreen struct {
+	initialized    bool
+	renderer       *sdl.Renderer
+	listComponent  *components.ListComponent
+	keyboard       *components.KeyboardComponent
+	configPath     string
+	defaultConfig  []byte
+	saved          settingsValues
+	draft          settingsValues
+	platforms      []string
+	fetched        chan []string
+	editingRepo    string
+	confirmDiscard bool
+	message        string
+}
+
+// NewSettingsScreen edits the settings and saves them to the config file at configPath.
+// defaultConfig is the base of a config file that doesn't exist yet.
+func NewSettingsScreen(renderer *sdl.Renderer, configPath string, defaultConfig []byte) (*SettingsScreen, error) {
+	listComponent := components.NewListComponent(
+		renderer,
+		vars.Config().Screen.MaxListItens,
+		vars.Config().Screen.MaxListItemWidth,
+		func(index int, item map[string]interface{}) string {
+			return fmt.Sprintf("%s: %s", item["label"].(string), item["value"].(func() string)())
+		})
+
+	return &SettingsScreen{
+		renderer:      renderer,
+		listComponent: listComponent,
+		keyboard:      components.NewKeyboardComponent(renderer, 40, 150),
+		configPath:    configPath,
+		defaultConfig: defaultConfig,
+		fetched:       make(chan []string, 1),
+	}, nil
+}
+
+func (s *SettingsScreen) InitSettings() {
+	if s.initialized {
+		return
+	}
+
+	s.saved = currentSettings()
+	s.draft = s.saved.clone()
+	s.editingRepo = ""
+	s.confirmDiscard = false
+	s.message = ""
+
+	s.platforms = []string{s.draft.Platform}
+	go s.fetchPlatforms()
+
+	s.listComponent.SetItems(s.settingsItems())
+
+	s.initialized = true
+}
+
+// currentSettings reads the settings in use
+func currentSettings() settingsValues {
+	config := vars.Config()
+	values := settingsValues{
+		Logs:         config.Logs,
+		Control:      config.Control["type"],
+		Width:        config.Screen.Width,
+		Height:       config.Screen.Height,
+		Volume:       config.Audio.Volume,
+		Music:        config.Audio.Music,
+		Platform:     vars.CurrentPlatform(),
+		Repositories: make(map[string]repositorySettings),
+	}
+	if values.Control == "" {
+		values.Control = controlTypes[0]
+	}
+
+	for key, repo := range config.Repositories {
+		values.Repositories[key] = repositorySettings{Path: repo.Path, HideInstalled: repo.HideInstalled}
+	}
+
+	return values
+}
+
+// fetchPlatforms reads the platforms of the handheld database in the background, for
+// Draw to list them. The current one is kept alone when they can't be fetched.
+func (s *SettingsScreen) fetchPlatforms() {
+	platforms, err := services.FetchPlatformsIndex()
+	if err != nil {
+		output.Errorf("Error fetching platforms: %v", err)
+		return
+	}
+
+	select {
+	case s.fetched <- platforms:
+	default: // A previous visit's list wasn't drawn yet
+	}
+}
+
+// listPlatforms offers the fetched platforms, keeping the selected one
+func (s *SettingsScreen) listPlatforms(platforms []string) {
+	for _, platform := range platforms {
+		if platform == s.draft.Platform {
+			s.platforms = platforms
+			return
+		}
+	}
+	s.platforms = append([]string{s.draft.Platform}, platforms...)
+}
+
+// settingsItems lists the settings. LEFT and RIGHT change the value of an item, A changes
+// it too, or edits it when it is typed.
+func (s *SettingsScreen) settingsItems() []map[string]interface{} {
+	items := []map[string]interface{}{
+		{
+			"label":  "Logs",
+			"value":  func() string { return onOff(s.draft.Logs) },
+			"change": func(int) { s.draft.Logs = !s.draft.Logs },
+		},
+		{
+			"label":   "Control type",
+			"restart": true,
+			"value":   func() string { return s.draft.Control },
+			"change": func(delta int) {
+				s.draft.Control = cycle(controlTypes, s.draft.Control, delta)
+			},
+		},
+		{
+			"label":   "Screen size",
+			"restart": true,
+			"value":   func() string { return fmt.Sprintf("%dx%d", s.draft.Width, s.draft.Height) },
+			"change":  s.changeScreenSize,
+		},
+		{
+			"label": "Volume",
+			"value": func() string { return fmt.Sprintf("%d%%", s.draft.Volume) },
+			"change": func(delta int) {
+				s.draft.Volume = (s.draft.Volume/10*10 + delta*10 + 110) % 110
+			},
+		},
+		{
+			"label":  "Music",
+			"value":  func() string { return onOff(s.draft.Music) },
+			"change": func(int) { s.draft.Music = !s.draft.Music },
+		},
+		{
+			"label": "Default platform",
+			"value": func() string { return s.draft.Platform },
+			"change": func(delta int) {
+				s.draft.Platform = cycle(s.platforms, s.draft.Platform, delta)
+			},
+		},
+	}
+
+	repoKeys := make([]string, 0, len(s.draft.Repositories))
+	for key := range s.draft.Repositories {
+		repoKeys = append(repoKeys, key)
+	}
+	sort.Strings(repoKeys)
+
+	for _, key := range repoKeys {
+		key := key
+		name := vars.Config().Repositories[key].Name
+		if name == "" {
+			name = key
+		}
+
+		items = append(items,
+			map[string]interface{}{
+				"label": name + " path",
+				"value": func() string { return s.draft.Repositories[key].Path },
+				"edit":  key,
+				"repo":  true,
+			},
+			map[string]interface{}{
+				"label": name + " hide installed",
+				"repo":  true,
+				"value": func() string { return onOff(s.draft.Repositories[key].HideInstalled) },
+				"change": func(int) {
+					repo := s.draft.Repositories[key]
+					repo.HideInstalled = !repo.HideInstalled
+					s.draft.Repositories[key] = repo
+				},
+			})
+	}
+
+	return items
+}
+
+// changeScreenSize moves to the next or previous resolution, the configured one included
+func (s *SettingsScreen) changeScreenSize(delta int) {
+	sizes := screenSizes
+	current := [2]int32{s.draft.Width, s.draft.Height}
+
+	index := -1
+	for i, size := range sizes {
+		if size == current {
+			index = i
+		}
+	}
+	if index == -1 {
+		sizes = append([][2]int32{current}, sizes...)
+		index = 0
+	}
+
+	next := sizes[(index+delta+len(sizes))%len(sizes)]
+	s.draft.Width, s.draft.Height = next[0], next[1]
+}
+
+func onOff(value bool) string {
+	if value {
+		return "On"
+	}
+	return "Off"
+}
+
+// cycle returns the option after or before current, the first one when current isn't listed
+func cycle(options []string, current string, delta int) string {
+	for i, option := range options {
+		if option == current {
+			return options[(i+delta+len(options))%len(options)]
+		}
+	}
+	return options[0]
+}
+
+// changes lists what the draft changes in the config file
+func (s *SettingsScreen) changes() []vars.ConfigChange {
+	var changes []vars.ConfigChange
+	add := func(value interface{}, path ...string) {
+		changes = append(changes, vars.ConfigChange{Path: path, Value: value})
+	}
+
+	if s.draft.Logs != s.saved.Logs {
+		add(s.draft.Logs, "logs")
+	}
+	if s.draft.Control != s.saved.Control {
+		add(s.draft.Control, "control", "type")
+	}
+	if s.draft.Width != s.saved.Width || s.draft.Height != s.saved.Height {
+		add(s.draft.Width, "screen", "width")
+		add(s.draft.Height, "screen", "height")
+	}
+	if s.draft.Volume != s.saved.Volume {
+		add(s.draft.Volume, "audio", "volume")
+	}
+	if s.draft.Music != s.saved.Music {
+		add(s.draft.Music, "audio", "music")
+	}
+	if s.draft.Platform != s.saved.Platform {
+		add(s.draft.Platform, "platform")
+	}
+
+	for key, repo := range s.draft.Repositories {
+		saved := s.saved.Repositories[key]
+		if repo.Path != saved.Path {
+			add(repo.Path, "repositories", key, "path")
+		}
+		if repo.HideInstalled != saved.HideInstalled {
+			add(repo.HideInstalled, "repositories", key, "hideinstalled")
+		}
+	}
+
+	return changes
+}
+
+// save writes the changes to the config file and applies the ones that don't need a restart
+func (s *SettingsScreen) save() {
+	changes := s.changes()
+	if len(changes) == 0 {
+		s.message = "Nothing to save"
+		return
+	}
+
+	issues, err := vars.SaveConfig(s.configPath, s.defaultConfig, changes)
+	if errors.Is(err, vars.ErrInvalidConfig) {
+		for _, issue := range issues {
+			if !issue.Warning {
+				s.message = "Not saved: " + issue.Message
+				break
+			}
+		}
+		return
+	}
+	if err != nil {
+		output.Errorf("Error saving settings: %v", err)
+		s.message = fmt.Sprintf("Not saved: %v", err)
+		return
+	}
+
+	s.apply()
+
+	s.message = "Saved to " + s.configPath
+	if s.draft.Control != s.saved.Control || s.draft.Width != s.saved.Width || s.draft.Height != s.saved.Height {
+		s.message = "Saved, some settings apply after restart"
+	}
+	s.saved = s.draft.clone()
+}
+
+// apply uses the saved settings right away, except the control type and the screen size.
+// The config is replaced by an updated copy, as downloads, exports and the search read it
+// from their own goroutines.
+func (s *SettingsScreen) apply() {
+	current := vars.Config()
+	config := *current
+	config.Logs = s.draft.Logs
+
+	if s.draft.Volume != s.saved.Volume {
+		config.Audio.Volume = s.draft.Volume
+		output.SetVolume(s.draft.Volume)
+	}
+
+	if s.draft.Music != s.saved.Music {
+		config.Audio.Music = s.draft.Music
+		if s.draft.Music {
+			output.PlaySound(BackgroundMusic, 5, true)
+		} else {
+			output.StopLoops()
+		}
+	}
+
+	if s.draft.Platform != s.saved.Platform {
+		config.Platform = s.draft.Platform
+		vars.SetCurrentPlatform(s.draft.Platform)
+	}
+
+	config.Repositories = make(map[string]vars.PlatformDetails, len(current.Repositories))
+	for key, repo := range current.Repositories {
+		if settings, ok := s.draft.Repositories[key]; ok {
+			repo.Path = settings.Path
+			repo.HideInstalled = settings.HideInstalled
+		}
+		config.Repositories[key] = repo
+	}
+
+	vars.SetConfig(&config)
+}
+
+func (s *SettingsScreen) HandleInput(event input.InputEvent) {
+	if s.editingRepo != "" {
+		s.handleEditInput(event)
+		return
+	}
+
+	selectedItem := s.listComponent.GetSelectedItem()
+	if event.KeyCode != "B" {
+		s.confirmDiscard = false
+	}
+
+	switch event.KeyCode {
+	case "DOWN":
+		s.listComponent.ScrollDown()
+	case "UP":
+		s.listComponent.ScrollUp()
+	case "L1":
+		s.listComponent.PageUp()
+	case "R1":
+		s.listComponent.PageDown()
+	case "LEFT", "RIGHT", "A":
+		if selectedItem == nil {
+			return
+		}
+		if key, ok := selectedItem["edit"].(string); ok {
+			if event.KeyCode == "A" {
+				s.editingRepo = key
+				s.keyboard.SetText(s.draft.Repositories[key].Path)
+			}
+			return
+		}
+
+		delta := 1
+		if event.KeyCode == "LEFT" {
+			delta = -1
+		}
+		selectedItem["change"].(func(int))(delta)
+		s.message = ""
+	case "X":
+		s.save()
+	case "B":
+		// Unsaved changes are only dropped when B is pressed twice
+		if len(s.changes()) > 0 && !s.confirmDiscard {
+			s.confirmDiscard = true
+			s.message = "Unsaved changes, press B again to discard them"
+			return
+		}
+		s.initialized = false
+		vars.CurrentScreen = "home_screen"
+	}
+}
+
+// handleEditInput types the path of a repository
+func (s *SettingsScreen) handleEditInput(event input.InputEvent) {
+	switch event.KeyCode {
+	case "UP":
+		s.keyboard.MoveUp()
+	case "DOWN":
+		s.keyboard.MoveDown()
+	case "LEFT", "L1":
+		s.keyboard.MoveLeft()
+	case "RIGHT", "R1":
+		s.keyboard.MoveRight()
+	case "A":
+		s.keyboard.Press()
+	case "Y":
+		s.keyboard.Delete()
+	case "X":
+		repo := s.draft.Repositories[s.editingRepo]
+		repo.Path = s.keyboard.GetText()
+		s.draft.Repositories[s.editingRepo] = repo
+		s.editingRepo = ""
+	case "B":
+		s.editingRepo = ""
+	}
+}
+
+func (s *SettingsScreen) Draw() {
+	s.InitSettings()
+
+	// Lists the platforms fetched in the background
+	select {
+	case platforms := <-s.fetched:
+		s.listPlatforms(platforms)
+	default:
+	}
+
+	s.renderer.SetDrawColor(255, 255, 255, 255)
+	s.renderer.Clear()
+
+	sdlutils.RenderTextureCartesian(s.renderer, "assets/textures/bg.bmp", "Q2", "Q4")
+
+	if s.editingRepo != "" {
+		sdlutils.DrawText(s.renderer, "Path: "+s.keyboard.GetText()+"_", sdl.Point{X: 25, Y: 25}, vars.Colors.WHITE, vars.HeaderFont)
+		sdlutils.DrawText(s.renderer, "A types, Y deletes, X confirms, B cancels", sdl.Point{X: 25, Y: 60}, vars.Colors.SECONDARY, vars.LongTextFont)
+
+		s.keyboard.Draw(vars.Colors.SECONDARY, vars.Colors.WHITE)
+	} else {
+		sdlutils.DrawText(s.renderer, "Settings", sdl.Point{X: 25, Y: 25}, vars.Colors.WHITE, vars.HeaderFont)
+
+		// Tells when the selected setting applies, unless there is news about the last save
+		message := s.message
+		if message == "" {
+			message = "Applies when saved. LEFT/RIGHT change, X saves"
+			if selectedItem := s.listComponent.GetSelectedItem(); selectedItem != nil {
+				if restart, _ := selectedItem["restart"].(bool); restart {
+					message = "Applies after restart. LEFT/RIGHT change, X saves"
+				} else if _, ok := selectedItem["edit"]; ok {
+					message = "Applies when saved. A edits, X saves. Other repository options are in config.json"
+				} else if repo, _ := selectedItem["repo"].(bool); repo {
+					message = "Applies when saved. LEFT/RIGHT change, X saves. Other repository options are in config.json"
+				}
+			}
+		}
+		sdlutils.DrawText(s.renderer, message, sdl.Point{X: 25, Y: 60}, vars.Colors.SECONDARY, vars.LongTextFont)
+
+		s.listComponent.Draw(vars.Colors.SECONDARY, vars.Colors.WHITE)
+	}
+
+	sdlutils.RenderTextureCartesian(s.renderer, "assets/textures/$aspect_ratio/ui_controls.bmp", "Q3", "Q4")
+
+	s.renderer.Present()
+}
@@ -0,0 +1,187 @@
+package screens
+
+import (
+	"fmt"
+	"handheldui/components"
+	"handheldui/helpers/sdlutils"
+	"handheldui/input"
+	"handheldui/output"
+	"handheldui/services"
+	"handheldui/vars"
+	"path"
+	"path/filepath"
+
+	"github.com/veandco/go-sdl2/sdl"
+)
+
+// syncPlanResult carries a sync plan computed in the background
+type syncPlanResult struct {
+	plan services.SyncPlan
+	err  error
+}
+
+type SyncScreen struct {
+	initialized   bool
+	renderer      *sdl.Renderer
+	listComponent *components.ListComponent
+	filesScreen   *FilesScreen
+	plan          services.SyncPlan
+	planned       chan syncPlanResult
+	isLoading     bool
+	message       string
+}
+
+func NewSyncScreen(renderer *sdl.Renderer, filesScreen *FilesScreen) (*SyncScreen, error) {
+	listComponent := components.NewListComponent(
+		renderer,
+		vars.Config().Screen.MaxListItens,
+		vars.Config().Screen.MaxListItemWidth,
+		func(index int, item map[string]interface{}) string {
+			if removed, ok := item["removed"].(services.InstalledFile); ok {
+				return fmt.Sprintf("[DEL] %s", filepath.Base(removed.Path))
+			}
+			return fmt.Sprintf("%s %s", fileStatusLabels[item["status"].(string)], path.Base(item["name"].(string)))
+		})
+
+	return &SyncScreen{
+		renderer:      renderer,
+		listComponent: listComponent,
+		filesScreen:   filesScreen,
+		planned:       make(chan syncPlanResult, 1),
+	}, nil
+}
+
+func (s *SyncScreen) InitSync() {
+	if s.initialized {
+		return
+	}
+
+	s.plan = services.SyncPlan{}
+	s.message = ""
+	s.listComponent.SetItems(nil)
+
+	s.isLoading = true
+	repoKey, repo := vars.CurrentRepo, vars.Config().Repositories[vars.CurrentRepo]
+	go func() {
+		plan, err := services.PlanSync(repoKey, repo)
+		s.planned <- syncPlanResult{plan: plan, err: err}
+	}()
+
+	s.initialized = true
+}
+
+// showPlan lists the changes a sync would make, computed in the background by comparing
+// the remote listings with the local files
+func (s *SyncScreen) showPlan(result syncPlanResult) {
+	s.isLoading = false
+	if result.err != nil {
+		output.Errorf("Error planning sync: %v", result.err)
+		s.message = downloadErrorMessage("Sync failed", "", result.err)
+		return
+	}
+
+	items := append([]map[string]interface{}{}, result.plan.Items...)
+	for _, removed := range result.plan.Removed {
+		items = append(items, map[string]interface{}{"removed": removed})
+	}
+
+	s.plan = result.plan
+	s.listComponent.SetItems(items)
+	s.message = s.summary()
+}
+
+// summary counts the planned changes by kind
+func (s *SyncScreen) summary() string {
+	if len(s.plan.Items) == 0 && len(s.plan.Removed) == 0 {
+		return "Everything is up to date"
+	}
+
+	added, changed := 0, 0
+	for _, item := range s.plan.Items {
+		if item["status"].(string) == services.StatusNew {
+			added++
+		} else {
+			changed++
+		}
+	}
+
+	return fmt.Sprintf("%d new, %d changed, %d removed. Press A to apply", added, changed, len(s.plan.Removed))
+}
+
+func (s *SyncScreen) HandleInput(event input.InputEvent) {
+	if s.isLoading {
+		return
+	}
+
+	switch event.KeyCode {
+	case "DOWN":
+		s.listComponent.ScrollDown()
+	case "UP":
+		s.listComponent.ScrollUp()
+	case "L1":
+		s.listComponent.PageUp()
+	case "R1":
+		s.listComponent.PageDown()
+	case "A":
+		if len(s.plan.Items) == 0 && len(s.plan.Removed) == 0 {
+			return
+		}
+		s.applyPlan()
+	case "B":
+		s.initialized = false
+		vars.CurrentScreen = "actions_screen"
+	}
+}
+
+// applyPlan removes the deleted files and queues the new and changed ones in the files list
+func (s *SyncScreen) applyPlan() {
+	if len(s.plan.Removed) > 0 {
+		if _, err := services.RemoveInstalledFiles(vars.CurrentRepo, s.plan.Removed); err != nil {
+			output.Errorf("Error removing files: %v", err)
+			s.message = fmt.Sprintf("Sync failed: %v", err)
+			return
+		}
+	}
+
+	s.initialized = false
+
+	if len(s.plan.Items) == 0 {
+		vars.CurrentScreen = "actions_screen"
+		return
+	}
+
+	s.filesScreen.QueueDownloads(s.plan.Items)
+}
+
+func (s *SyncScreen) Draw() {
+	s.InitSync()
+
+	// Lists the plan computed in the background
+	select {
+	case result := <-s.planned:
+		s.showPlan(result)
+	default:
+	}
+
+	s.renderer.SetDrawColor(255, 255, 255, 255)
+	s.renderer.Clear()
+
+	sdlutils.RenderTextureCartesian(s.renderer, "assets/textures/bg.bmp", "Q2", "Q4")
+
+	sdlutils.DrawText(s.renderer, "Sync: "+repositoryTitle(vars.Config().Repositories[vars.CurrentRepo]), sdl.Point{X: 25, Y: 25}, vars.Colors.WHITE, vars.HeaderFont)
+
+	// Draws the summary of the planned changes
+	message := s.message
+	if s.isLoading {
+		message = "Checking for changes..."
+	}
+	if message != "" {
+		sdlutils.DrawText(s.renderer, message, sdl.Point{X: 25, Y: 60}, vars.Colors.WHITE, vars.LongTextFont)
+	}
+
+	s.listComponent.Draw(vars.Colors.SECONDARY, vars.Colors.WHITE)
+
+	sdlutils.RenderTextureCartesian(s.renderer, "assets/textures/$aspect_ratio/ui_controls.bmp", "Q3", "Q4")
+
+	s.renderer.Present()
+}
@@ -22,14 +22,14 @@ type SystemsScreen struct {
 func NewSystemsScreen(renderer *sdl.Renderer) (*SystemsScreen, error) {
 	listComponent := components.NewListComponent(
 		renderer,
-		vars.Config.Screen.MaxListItens,
-		vars.Config.Screen.MaxListItemWidth,
+		vars.Config().Screen.MaxListItens,
+		vars.Config().Screen.MaxListItemWidth,
 		func(index int, item map[string]interface{}) string {
 			return fmt.Sprintf("%d. %s", index+1, item["name"].(string))
 		})
 
 	s := &SystemsScreen{
-		detectedPlatform: vars.CurrentPlatform,
+		detectedPlatform: vars.CurrentPlatform(),
 		renderer:         renderer,
 		listComponent:    listComponent,
 	}
@@ -38,13 +38,15 @@ func NewSystemsScreen(renderer *sdl.Renderer) (*SystemsScreen, error) {
 }
 
 func (s *SystemsScreen) InitSystems() {
-	if s.initialized {
+	// The default platform can be changed in the settings
+	if s.initialized && s.detectedPlatform == vars.CurrentPlatform() {
 		return
 	}
+	s.detectedPlatform = vars.CurrentPlatform()
 
 	systemsData, err := services.FetchPlatform(s.detectedPlatform)
 	if err != nil {
-		output.Errorf("Error fetching platform data:", err)
+		output.Errorf("Error fetching platform data: %v", err)
 		return
 	}
 
@@ -2,16 +2,13 @@ package services
 
 import (
 	"context"
-	"encoding/json"
-	"encoding/xml"
-	"fmt"
+	"errors"
+	"handheldui/helpers/network"
 	"handheldui/output"
 	"io"
 	"net/http"
 	"os"
 	"path/filepath"
-	"sort"
-	"strings"
 	"sync"
 )
 
@@ -21,124 +18,70 @@ var (
 
 // File represents the structure of a file in the XML metadata.
 type File struct {
-	Name string `xml:"name,attr"`
+	Name   string `xml:"name,attr" json:"name"`
+	Source string `xml:"source,attr" json:"source,omitempty"`
+	Size   int64  `xml:"size" json:"size,omitempty"`
+	MD5    string `xml:"md5" json:"md5,omitempty"`
+	SHA1   string `xml:"sha1" json:"sha1,omitempty"`
+	URL    string `xml:"-" json:"url"`
 }
 
-// Files represents the root structure of the XML metadata.
-type Files struct {
-	File []File `xml:"file"`
-}
-
-// Returns the cache file path specific to the given name
-func getCacheFilePath(name string) string {
-	return filepath.Join(".cache", "archive_metadata", fmt.Sprintf("cache_%s.json", name))
-}
-
-func loadCacheFromFile(name string) (map[string]string, error) {
-	cacheFilePath := getCacheFilePath(name)
-	cache := make(map[string]string)
-
-	// Tries to open the cache file
-	cacheFile, err := os.Open(cacheFilePath)
-	if err != nil {
-		if os.IsNotExist(err) {
-			// If the file doesn't exist, returns an empty cache
-			return cache, nil
-		}
-		return nil, output.Errorf("error opening cache file: %v", err)
+// FetchMetadata returns the listing index of a collection, downloading it when missing
+func FetchMetadata(name string) (*FileIndex, error) {
+	cacheLock.RLock()
+	index, err := OpenIndex(getIndexFilePath(name))
+	cacheLock.RUnlock()
+	if err == nil {
+		return index, nil
 	}
-	defer cacheFile.Close()
-
-	// Decodes the data into the cache
-	if err := json.NewDecoder(cacheFile).Decode(&cache); err != nil {
-		return nil, output.Errorf("error decoding cache data: %v", err)
+	if !os.IsNotExist(err) {
+		output.Errorf("Error opening listing index of %s, downloading it again: %v", name, err)
 	}
 
-	return cache, nil
+	return RefreshMetadata(name)
 }
 
-func saveCacheToFile(name string, cache map[string]string) error {
-	cacheFilePath := getCacheFilePath(name)
-
-	// Creates necessary directories if they don't exist
-	if err := os.MkdirAll(filepath.Dir(cacheFilePath), os.ModePerm); err != nil {
-		return output.Errorf("error creating cache directories: %v", err)
-	}
-
-	// Creates or overwrites the cache file
-	cacheFile, err := os.Create(cacheFilePath)
-	if err != nil {
-		return output.Errorf("error creating or overwriting cache file: %v", err)
-	}
-	defer cacheFile.Close()
-
-	// Encodes the cache data to the file
-	if err := json.NewEncoder(cacheFile).Encode(cache); err != nil {
-		return output.Errorf("error encoding cache data: %v", err)
+// RefreshMetadata downloads the listing of a collection again, replacing the cached one.
+// Mirrors are tried in order, and the cache is kept when every mirror fails.
+func RefreshMetadata(name string) (*FileIndex, error) {
+	var lastErr error
+	for _, base := range orderedMirrors(collectionMirrors(name)) {
+		index, err := fetchMetadataFrom(base, name)
+		markMirror(base, err)
+		if err == nil {
+			return index, nil
+		}
+		if lastErr == nil || !IsAuthError(lastErr) {
+			lastErr = err
+		}
 	}
 
-	return nil
+	return nil, lastErr
 }
 
-func FetchMetadata(name string) (map[string]string, error) {
-	cacheLock.RLock()
-	cache, err := loadCacheFromFile(name)
-	cacheLock.RUnlock()
-	if err != nil {
-		return nil, err
-	}
-
-	// If the cache already contains data, return it
-	if len(cache) > 0 {
-		return cache, nil
-	}
-
+// fetchMetadataFrom streams the listing of a collection from a mirror into its index
+func fetchMetadataFrom(base, name string) (*FileIndex, error) {
 	// Downloads the metadata from the URL
-	resp, err := http.Get(fmt.Sprintf("https://archive.org/download/%s/%s_files.xml", name, name))
+	link := mirrorFileURL(base, name, name+"_files.xml")
+	resp, err := network.GetWithContext(withCollectionAuth(context.Background(), name), link)
 	if err != nil {
 		return nil, output.Errorf("error fetching metadata for %s: %v", name, err)
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		return nil, output.Errorf("error fetching metadata for %s: %v", name, resp.Status)
-	}
-
-	// Decodes the metadata
-	var metadata Files
-	if err := xml.NewDecoder(resp.Body).Decode(&metadata); err != nil {
-		return nil, output.Errorf("error decoding response: %v", err)
-	}
-
-	// Processes the metadata
-	metadataList := make(map[string]string)
-	for _, file := range metadata.File {
-		escapedFileName := strings.ReplaceAll(file.Name, " ", "%20")
-		metadataList[file.Name] = fmt.Sprintf("https://archive.org/download/%s/%s", name, escapedFileName)
-	}
-
-	// Sorts the files by name
-	var sortedFileNames []string
-	for fileName := range metadataList {
-		sortedFileNames = append(sortedFileNames, fileName)
-	}
-	sort.Strings(sortedFileNames)
-
-	// Creates a sorted list of metadata
-	sortedMetadataList := make(map[string]string)
-	for _, fileName := range sortedFileNames {
-		sortedMetadataList[fileName] = metadataList[fileName]
+		err := &StatusError{URL: link, Status: resp.Status, Code: resp.StatusCode}
+		output.Errorf("%v", err)
+		return nil, err
 	}
 
-	// Updates the cache
-	cacheLock.Lock()
-	err = saveCacheToFile(name, sortedMetadataList)
-	cacheLock.Unlock()
+	// Files are decoded one by one and written sorted to the index
+	index, err := buildIndex(getIndexFilePath(name), name, base, resp.Body)
 	if err != nil {
-		return nil, err
+		return nil, output.Errorf("error indexing metadata for %s: %v", name, err)
 	}
 
-	return sortedMetadataList, nil
+	return index, nil
 }
 
 func DownloadFile(ctx context.Context, path, filename, link string, progress func(int64, int64)) error {
@@ -151,20 +94,42 @@ func DownloadFile(ctx context.Context, path, filename, link string, progress fun
 	sanitizedFilename := filepath.Base(filename)
 	fullPath := filepath.Join(path, sanitizedFilename)
 
+	// Large files are split into ranges when the server accepts them
+	if chunks, threshold := chunkSettings(); chunks > 1 {
+		if size, ranged := probeRanges(ctx, link); ranged && size >= threshold {
+			err := downloadChunked(ctx, fullPath, link, size, chunks, progress)
+			if errors.Is(err, errRangeIgnored) {
+				output.Printf("%s ignored the ranged requests, downloading it whole\n", link)
+				return downloadWhole(ctx, fullPath, link, progress)
+			}
+			if err != nil {
+				os.Remove(fullPath)
+			}
+			return err
+		}
+	}
+
+	return downloadWhole(ctx, fullPath, link, progress)
+}
+
+// downloadWhole downloads a file in a single request
+func downloadWhole(ctx context.Context, fullPath, link string, progress func(int64, int64)) error {
 	// Starts the download
 	req, err := http.NewRequestWithContext(ctx, http.MethodGet, link, nil)
 	if err != nil {
 		return output.Errorf("error creating request for %s: %v", link, err)
 	}
 
-	resp, err := http.DefaultClient.Do(req)
+	resp, err := network.Do(req)
 	if err != nil {
 		return output.Errorf("error downloading file from %s: %v", link, err)
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		return output.Errorf("error downloading file from %s: %v", link, resp.Status)
+		err := &StatusError{URL: link, Status: resp.Status, Code: resp.StatusCode}
+		output.Errorf("%v", err)
+		return err
 	}
 
 	totalSize := resp.ContentLength
@@ -174,6 +139,9 @@ func DownloadFile(ctx context.Context, path, filename, link string, progress fun
 	}
 	defer out.Close()
 
+	// Reads within the global and per-download bandwidth limits
+	body := network.LimitReader(ctx, resp.Body, network.NewDownloadBucket())
+
 	var downloaded int64
 	buf := make([]byte, 32*1024)
 	for {
@@ -181,7 +149,7 @@ func DownloadFile(ctx context.Context, path, filename, link string, progress fun
 		case <-ctx.Done(): // Monitors cancellation
 			return output.Errorf("download cancelled")
 		default:
-			n, err := resp.Body.Read(buf)
+			n, err := body.Read(buf)
 			if n > 0 {
 				downloaded += int64(n)
 				progress(downloaded, totalSize)
@@ -191,7 +159,7 @@ func DownloadFile(ctx context.Context, path, filename, link string, progress fun
 				}
 			}
 			if err == io.EOF {
-				break
+				return nil
 			}
 			if err != nil {
 				return output.Errorf("error downloading file %s: %v", link, err)
@@ -0,0 +1,236 @@
+package services
+
+import (
+	"context"
+	"handheldui/helpers/image"
+	"handheldui/output"
+	"handheldui/vars"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"unicode"
+)
+
+var (
+	systemGames      = make(map[string][]map[string]interface{})
+	systemGamesMutex sync.Mutex
+)
+
+// fetchSystemGames returns the database games of a system, fetched once per session
+func fetchSystemGames(system string) ([]map[string]interface{}, error) {
+	systemGamesMutex.Lock()
+	defer systemGamesMutex.Unlock()
+
+	if games, ok := systemGames[system]; ok {
+		return games, nil
+	}
+
+	games, err := FetchGames(vars.CurrentPlatform(), system)
+	if err != nil {
+		return nil, err
+	}
+
+	systemGames[system] = games
+	return games, nil
+}
+
+// normalizeTitle reduces a game name to lowercase letters and digits, moving a trailing
+// article like in "Legend of Zelda, The - A Link to the Past" back to the front.
+func normalizeTitle(title string) string {
+	title = strings.ToLower(strings.TrimSpace(title))
+
+	mainTitle, subtitle := splitSubtitle(title)
+	for _, article := range []string{"the", "a", "an"} {
+		if strings.HasSuffix(mainTitle, ", "+article) {
+			title = article + " " + strings.TrimSuffix(mainTitle, ", "+article) + subtitle
+		}
+	}
+	title = strings.ReplaceAll(title, "&", "and")
+
+	var builder strings.Builder
+	for _, r := range title {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			builder.WriteRune(r)
+		}
+	}
+	return builder.String()
+}
+
+// splitSubtitle splits a title at the first " - " or ": " separator
+func splitSubtitle(title string) (string, string) {
+	index := strings.Index(title, " - ")
+	if colon := strings.Index(title, ": "); colon >= 0 && (index < 0 || colon < index) {
+		index = colon
+	}
+	if index < 0 {
+		return title, ""
+	}
+	return title[:index], title[index:]
+}
+
+// MatchGame returns the database game with the same title as a ROM file, ignoring the
+// tags of the file name, or nil when there is none.
+func MatchGame(games []map[string]interface{}, romName string) map[string]interface{} {
+	romTitle := ParseRomTags(romName).Title
+	title := normalizeTitle(romTitle)
+	if title == "" {
+		return nil
+	}
+
+	for _, game := range games {
+		name, _ := game["name"].(string)
+		if normalizeTitle(name) == title {
+			return game
+		}
+	}
+
+	// One of the names may drop the subtitle, so the titles before the separator also match
+	mainTitle, _ := splitSubtitle(romTitle)
+	mainTitle = normalizeTitle(mainTitle)
+	for _, game := range games {
+		name, _ := game["name"].(string)
+		gameTitle, _ := splitSubtitle(name)
+		if normalizeTitle(name) == mainTitle || normalizeTitle(gameTitle) == title {
+			return game
+		}
+	}
+
+	return nil
+}
+
+// ArtworkPath returns where the frontend expects the image of a ROM
+func ArtworkPath(romPath string, artwork vars.ArtworkDetails) string {
+	folder := artwork.Folder
+	if folder == "" {
+		folder = "{dir}/Imgs"
+	}
+
+	name := artwork.Name
+	if name == "" {
+		name = "{stem}.png"
+	}
+
+	return filepath.Join(expandPlaceholders(folder, romPath, false), expandPlaceholders(name, romPath, false))
+}
+
+// ExportArtwork writes the cover of the game matching a ROM into the image folder of the
+// frontend. It returns false when the ROM already has an image or no game matches it.
+func ExportArtwork(romPath, system string, artwork vars.ArtworkDetails) (bool, error) {
+	if system == "" {
+		return false, nil
+	}
+
+	destPath := ArtworkPath(romPath, artwork)
+	if _, err := os.Stat(destPath); err == nil {
+		return false, nil
+	}
+
+	games, err := fetchSystemGames(system)
+	if err != nil {
+		return false, err
+	}
+
+	game := MatchGame(games, filepath.Base(romPath))
+	if game == nil {
+		output.Printf("No game found for %s\n", romPath)
+		return false, nil
+	}
+
+	coverPath := image.FetchGameImage(game["key"].(string), "cover")
+	if coverPath == "" {
+		return false, output.Errorf("cover not found for %s", game["name"])
+	}
+
+	if err := image.ExportImage(coverPath, destPath, artwork.Width, artwork.Height); err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+// ExportInstalledArtwork exports the artwork of the ROMs of an installed file. Cue tracks
+// and files outside the extension list of the repository are skipped.
+func ExportInstalledArtwork(installed InstalledFile, repo vars.PlatformDetails, artwork vars.ArtworkDetails) error {
+	for _, romPath := range installed.Paths() {
+		if !isGameFile(romPath, repo.ExtList) {
+			continue
+		}
+		if _, err := ExportArtwork(romPath, repo.System, artwork); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ScrapeLibrary exports the artwork of every ROM already inside the repository path.
+// The progress callback receives the current file and how many files were done before it.
+func ScrapeLibrary(ctx context.Context, repo vars.PlatformDetails, artwork vars.ArtworkDetails, progress func(name string, done, total int)) (int, error) {
+	if repo.System == "" {
+		return 0, output.Errorf("repository %s has no database system", repo.Name)
+	}
+
+	imageFolders := make(map[string]bool)
+
+	var roms []string
+	err := filepath.WalkDir(repo.Path, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if entry.IsDir() {
+			if imageFolders[path] {
+				return filepath.SkipDir
+			}
+			// Skips the image folder of the frontend, like Imgs, when it lives inside the ROMs folder
+			imageFolders[filepath.Dir(ArtworkPath(filepath.Join(path, "rom"), artwork))] = true
+			return nil
+		}
+		if isGameFile(path, repo.ExtList) {
+			roms = append(roms, path)
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, output.Errorf("error reading %s: %v", repo.Path, err)
+	}
+
+	exported := 0
+	for index, romPath := range roms {
+		if ctx.Err() != nil {
+			return exported, output.Errorf("scrape cancelled")
+		}
+
+		progress(filepath.Base(romPath), index, len(roms))
+
+		ok, err := ExportArtwork(romPath, repo.System, artwork)
+		if err != nil {
+			output.Errorf("Error exporting artwork for %s: %v", romPath, err)
+			continue
+		}
+		if ok {
+			exported++
+		}
+	}
+
+	return exported, nil
+}
+
+// isGameFile checks if a file is a game, skipping cue tracks and files outside the extension list
+func isGameFile(romPath string, extList []string) bool {
+	ext := strings.ToLower(filepath.Ext(romPath))
+	if ext == "" || (cueTrackExtensions[ext] && ext != ".cue") {
+		return false
+	}
+
+	if len(extList) == 0 {
+		return true
+	}
+
+	for _, allowed := range extList {
+		if strings.EqualFold(ext, allowed) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,120 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"handheldui/helpers/network"
+	"handheldui/vars"
+	"net/http"
+	"strings"
+)
+
+// Credential types of the secrets file
+const (
+	AuthCookie = "cookie"
+	AuthS3     = "s3"
+	AuthBasic  = "basic"
+	AuthBearer = "bearer"
+)
+
+// archiveHosts receive the archive.org credentials when no hosts are configured
+var archiveHosts = []string{"archive.org"}
+
+// withCollectionAuth returns a context whose requests carry the credentials of a collection
+func withCollectionAuth(ctx context.Context, collection string) context.Context {
+	hook := collectionAuthHook(collection)
+	if hook == nil {
+		return ctx
+	}
+	return network.WithRequestHook(ctx, hook)
+}
+
+// collectionAuthHook returns the function attaching the credentials of a collection to a
+// request, nil when the collection has none
+func collectionAuthHook(collection string) func(*http.Request) {
+	credential, ok := collectionCredential(collection)
+	if !ok {
+		return nil
+	}
+
+	return func(req *http.Request) {
+		authorizeRequest(req, credential)
+	}
+}
+
+// collectionCredential returns the credential of a collection. Members of a parent or
+// favorites entry use the credential of their entry.
+func collectionCredential(collection string) (vars.CredentialDetails, bool) {
+	if vars.Secrets == nil {
+		return vars.CredentialDetails{}, false
+	}
+
+	credential, ok := vars.Secrets.Collections[collection]
+	if !ok {
+		details, found := findCollection(collection)
+		if !found || details.Parent == "" {
+			return vars.CredentialDetails{}, false
+		}
+		credential, ok = vars.Secrets.Collections[details.Parent]
+	}
+
+	return credential, ok
+}
+
+// authorizeRequest attaches a credential to a request sent to one of its hosts. Credentials
+// are only sent without TLS when they are marked insecure.
+func authorizeRequest(req *http.Request, credential vars.CredentialDetails) {
+	if req.URL.Scheme != "https" && !credential.Insecure {
+		return
+	}
+
+	hosts := credential.Hosts
+	if len(hosts) == 0 && (credential.Type == AuthCookie || credential.Type == AuthS3) {
+		hosts = archiveHosts
+	}
+
+	if len(hosts) > 0 && !matchesHost(req.URL.Hostname(), hosts) {
+		return
+	}
+
+	switch credential.Type {
+	case AuthCookie:
+		req.Header.Set("Cookie", credential.Cookie)
+	case AuthS3:
+		req.Header.Set("Authorization", "LOW "+credential.AccessKey+":"+credential.SecretKey)
+	case AuthBasic:
+		req.SetBasicAuth(credential.Username, credential.Password)
+	case AuthBearer:
+		req.Header.Set("Authorization", "Bearer "+credential.Token)
+	}
+}
+
+// matchesHost checks if host is one of the hosts or one of their subdomains
+func matchesHost(host string, hosts []string) bool {
+	host = strings.ToLower(host)
+	for _, allowed := range hosts {
+		allowed = strings.ToLower(allowed)
+		if host == allowed || strings.HasSuffix(host, "."+allowed) {
+			return true
+		}
+	}
+	return false
+}
+
+// IsAuthError checks if a request failed because the credentials are missing or wrong.
+func IsAuthError(err error) bool {
+	var statusErr *StatusError
+	return errors.As(err, &statusErr) && (statusErr.Code == http.StatusUnauthorized || statusErr.Code == http.StatusForbidden)
+}
+
+// IsCredentialWithheld checks if a request was refused because the credential of the
+// collection was kept from a plain http URL, as it isn't marked insecure.
+func IsCredentialWithheld(err error, collection string) bool {
+	var statusErr *StatusError
+	if !IsAuthError(err) || !errors.As(err, &statusErr) || !strings.HasPrefix(strings.ToLower(statusErr.URL), "http://") {
+		return false
+	}
+
+	credential, ok := collectionCredential(collection)
+	return ok && !credential.Insecure
+}
@@ -0,0 +1,212 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha1"
+	"encoding/pem"
+	"fmt"
+	"handheldui/helpers/network"
+	"handheldui/vars"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestAuthorizeRequest(t *testing.T) {
+	tests := []struct {
+		name       string
+		url        string
+		credential vars.CredentialDetails
+		want       string
+	}{
+		{
+			name:       "cookie on archive.org",
+			url:        "https://ia800100.us.archive.org/item/file.zip",
+			credential: vars.CredentialDetails{Type: AuthCookie, Cookie: "logged-in-user=me"},
+			want:       "Cookie: logged-in-user=me",
+		},
+		{
+			name:       "cookie on another host",
+			url:        "https://mirror.example/item/file.zip",
+			credential: vars.CredentialDetails{Type: AuthCookie, Cookie: "logged-in-user=me"},
+		},
+		{
+			name:       "cookie without tls",
+			url:        "http://bt1.archive.org/announce",
+			credential: vars.CredentialDetails{Type: AuthCookie, Cookie: "logged-in-user=me"},
+		},
+		{
+			name:       "bearer on its host",
+			url:        "https://files.example/item/file.zip",
+			credential: vars.CredentialDetails{Type: AuthBearer, Token: "secret", Hosts: []string{"example"}},
+			want:       "Authorization: Bearer secret",
+		},
+		{
+			name:       "bearer without tls",
+			url:        "http://files.example/item/file.zip",
+			credential: vars.CredentialDetails{Type: AuthBearer, Token: "secret", Hosts: []string{"example"}},
+		},
+		{
+			name:       "insecure basic without tls",
+			url:        "http://192.168.0.10/item/file.zip",
+			credential: vars.CredentialDetails{Type: AuthBasic, Username: "user", Password: "secret", Hosts: []string{"192.168.0.10"}, Insecure: true},
+			want:       "Authorization: Basic dXNlcjpzZWNyZXQ=",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			req, err := http.NewRequest(http.MethodGet, test.url, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			authorizeRequest(req, test.credential)
+
+			var got string
+			for _, header := range []string{"Cookie", "Authorization"} {
+				if value := req.Header.Get(header); value != "" {
+					got = header + ": " + value
+				}
+			}
+			if got != test.want {
+				t.Errorf("authorizeRequest() set %q, want %q", got, test.want)
+			}
+		})
+	}
+}
+
+// TestDownloadTorrentFileAuth downloads a torrent file from a web seed asking for
+// credentials, checking they never reach the tracker
+func TestDownloadTorrentFileAuth(t *testing.T) {
+	content := bytes.Repeat([]byte("handheld"), 4096)
+
+	var (
+		mu             sync.Mutex
+		trackerHeaders []http.Header
+	)
+	tracker := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		trackerHeaders = append(trackerHeaders, r.Header.Clone())
+		mu.Unlock()
+		w.Write([]byte("d5:peers0:e"))
+	}))
+	defer tracker.Close()
+
+	torrentFile := singleFileTorrent("game.bin", content, 8192, tracker.URL+"/announce")
+
+	archive := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if user, password, ok := r.BasicAuth(); !ok || user != "user" || password != "secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		switch r.URL.Path {
+		case "/item/item_archive.torrent":
+			w.Write(torrentFile)
+		case "/item/game.bin":
+			http.ServeContent(w, r, "game.bin", time.Time{}, bytes.NewReader(content))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer archive.Close()
+
+	// Trusts the certificate of the test server
+	certificate := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: archive.Certificate().Raw})
+	if err := network.ConfigureTransport(network.TransportSettings{Bundles: [][]byte{certificate}}); err != nil {
+		t.Fatal(err)
+	}
+	defer network.ConfigureTransport(network.TransportSettings{})
+
+	previousConfig, previousSecrets := vars.Config(), vars.Secrets
+	vars.SetConfig(&vars.ConfigDefinition{URLs: vars.URLDetails{Archive: archive.URL}})
+	vars.Secrets = &vars.SecretsDefinition{Collections: map[string]vars.CredentialDetails{
+		"item": {Type: AuthBasic, Username: "user", Password: "secret", Hosts: []string{"127.0.0.1"}},
+	}}
+	defer func() { vars.SetConfig(previousConfig); vars.Secrets = previousSecrets }()
+
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(wd)
+
+	file := File{Name: "game.bin", Size: int64(len(content)), URL: archive.URL + "/item/game.bin"}
+	if err := DownloadTorrentFile(context.Background(), "item", filepath.Join(dir, "roms"), "game.bin", file, func(int64, int64) {}); err != nil {
+		t.Fatalf("DownloadTorrentFile() error = %v", err)
+	}
+
+	got, err := os.ReadFile(filepath.Join(dir, "roms", "game.bin"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, content) {
+		t.Error("downloaded file differs from the served content")
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(trackerHeaders) == 0 {
+		t.Fatal("the tracker was never announced to")
+	}
+	for _, header := range trackerHeaders {
+		if header.Get("Authorization") != "" || header.Get("Cookie") != "" {
+			t.Errorf("the tracker received credentials: %v", header)
+		}
+	}
+}
+
+// singleFileTorrent bencodes the torrent of a single file
+func singleFileTorrent(name string, data []byte, pieceLength int, announce string) []byte {
+	var pieces strings.Builder
+	for start := 0; start < len(data); start += pieceLength {
+		end := start + pieceLength
+		if end > len(data) {
+			end = len(data)
+		}
+		hash := sha1.Sum(data[start:end])
+		pieces.Write(hash[:])
+	}
+
+	info := fmt.Sprintf("d6:lengthi%de4:name%d:%s12:piece lengthi%de6:pieces%d:%se",
+		len(data), len(name), name, pieceLength, pieces.Len(), pieces.String())
+	return []byte(fmt.Sprintf("d8:announce%d:%s4:info%se", len(announce), announce, info))
+}
+
+func TestIsCredentialWithheld(t *testing.T) {
+	previous := vars.Secrets
+	vars.Secrets = &vars.SecretsDefinition{Collections: map[string]vars.CredentialDetails{
+		"private":  {Type: AuthBasic, Username: "user", Password: "secret"},
+		"insecure": {Type: AuthBasic, Username: "user", Password: "secret", Insecure: true},
+	}}
+	defer func() { vars.Secrets = previous }()
+
+	tests := []struct {
+		name       string
+		collection string
+		err        error
+		want       bool
+	}{
+		{"refused over http", "private", &StatusError{URL: "http://192.168.0.10/private/game.zip", Code: http.StatusUnauthorized}, true},
+		{"refused over https", "private", &StatusError{URL: "https://files.example/private/game.zip", Code: http.StatusUnauthorized}, false},
+		{"insecure credential", "insecure", &StatusError{URL: "http://192.168.0.10/insecure/game.zip", Code: http.StatusUnauthorized}, false},
+		{"not found", "private", &StatusError{URL: "http://192.168.0.10/private/game.zip", Code: http.StatusNotFound}, false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := IsCredentialWithheld(test.err, test.collection); got != test.want {
+				t.Errorf("IsCredentialWithheld() = %v, want %v", got, test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,194 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"handheldui/helpers/network"
+	"handheldui/output"
+	"handheldui/vars"
+	"io"
+	"net/http"
+	"os"
+	"sync"
+)
+
+const (
+	// defaultChunkThreshold is the smallest file split into chunks when none is configured
+	defaultChunkThreshold = 64 * 1024 * 1024
+	// chunkAttempts is how many times a chunk is requested before the download fails
+	chunkAttempts = 3
+)
+
+// errRangeIgnored is returned when a server answers a ranged request with the whole file
+var errRangeIgnored = errors.New("the server ignored the requested range")
+
+// chunkSettings returns the configured chunk count and the smallest size that is split
+func chunkSettings() (int, int64) {
+	if vars.Config() == nil {
+		return 1, 0
+	}
+
+	threshold := vars.Config().Download.ChunkThreshold
+	if threshold <= 0 {
+		threshold = defaultChunkThreshold
+	}
+
+	return vars.Config().Download.Chunks, threshold
+}
+
+// probeRanges asks the server for the size of a file and whether it accepts ranged requests
+func probeRanges(ctx context.Context, link string) (int64, bool) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, link, nil)
+	if err != nil {
+		return 0, false
+	}
+
+	resp, err := network.Do(req)
+	if err != nil {
+		return 0, false
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return 0, false
+	}
+
+	return resp.ContentLength, resp.Header.Get("Accept-Ranges") == "bytes"
+}
+
+// downloadChunked splits a file into ranges downloaded at the same time into one
+// preallocated file. Progress is reported for the whole file.
+func downloadChunked(ctx context.Context, fullPath, link string, size int64, chunks int, progress func(int64, int64)) error {
+	out, err := os.Create(fullPath)
+	if err != nil {
+		return output.Errorf("error creating file %s: %v", fullPath, err)
+	}
+	defer out.Close()
+
+	if err := out.Truncate(size); err != nil {
+		return output.Errorf("error allocating file %s: %v", fullPath, err)
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	// Every chunk shares the bandwidth limit of the download
+	bucket := network.NewDownloadBucket()
+
+	var (
+		wg            sync.WaitGroup
+		progressMutex sync.Mutex
+		downloaded    int64
+		firstErr      error
+	)
+
+	report := func(n int64) {
+		progressMutex.Lock()
+		defer progressMutex.Unlock()
+		downloaded += n
+		progress(downloaded, size)
+	}
+
+	chunkSize := (size + int64(chunks) - 1) / int64(chunks)
+	for start := int64(0); start < size; start += chunkSize {
+		end := start + chunkSize - 1
+		if end >= size {
+			end = size - 1
+		}
+
+		wg.Add(1)
+		go func(start, end int64) {
+			defer wg.Done()
+
+			if err := downloadRange(ctx, out, link, start, end, bucket, report); err != nil {
+				progressMutex.Lock()
+				if firstErr == nil {
+					firstErr = err
+				}
+				progressMutex.Unlock()
+				// One failed chunk fails the whole file, so the others stop
+				cancel()
+			}
+		}(start, end)
+	}
+
+	wg.Wait()
+
+	if firstErr != nil {
+		return firstErr
+	}
+
+	return nil
+}
+
+// downloadRange writes the bytes from start to end of a file at the same offsets of out,
+// resuming from the last written byte when the connection drops.
+func downloadRange(ctx context.Context, out *os.File, link string, start, end int64, bucket *network.Bucket, report func(int64)) error {
+	offset := start
+	var lastErr error
+
+	for attempt := 0; attempt < chunkAttempts && offset <= end; attempt++ {
+		if ctx.Err() != nil {
+			return output.Errorf("download cancelled")
+		}
+
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, link, nil)
+		if err != nil {
+			return output.Errorf("error creating request for %s: %v", link, err)
+		}
+		req.Header.Set("Range", fmt.Sprintf("bytes=%d-%d", offset, end))
+
+		resp, err := network.Do(req)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+
+		// Servers may announce ranges and still send the whole file
+		if resp.StatusCode == http.StatusOK {
+			resp.Body.Close()
+			return output.Errorf("error downloading range of %s: %w", link, errRangeIgnored)
+		}
+		if resp.StatusCode != http.StatusPartialContent {
+			resp.Body.Close()
+			return output.Errorf("error downloading range of %s: %v", link, resp.Status)
+		}
+
+		body := network.LimitReader(ctx, resp.Body, bucket)
+		buf := make([]byte, 32*1024)
+		for offset <= end {
+			n, err := body.Read(buf)
+			if n > 0 {
+				// Never writes past the requested range, even if the server sends more
+				if remaining := end - offset + 1; int64(n) > remaining {
+					n = int(remaining)
+				}
+				if _, writeErr := out.WriteAt(buf[:n], offset); writeErr != nil {
+					resp.Body.Close()
+					return output.Errorf("error saving file %s: %v", out.Name(), writeErr)
+				}
+				offset += int64(n)
+				report(int64(n))
+			}
+			if err == io.EOF {
+				lastErr = io.ErrUnexpectedEOF
+				break
+			}
+			if err != nil {
+				lastErr = err
+				break
+			}
+		}
+		resp.Body.Close()
+	}
+
+	if offset <= end {
+		if ctx.Err() != nil {
+			return output.Errorf("download cancelled")
+		}
+		return output.Errorf("error downloading range of %s: %v", link, lastErr)
+	}
+
+	return nil
+}
@@ -0,0 +1,172 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"handheldui/vars"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"testing"
+)
+
+// rangeServer serves content, answering ranged requests unless ignoreRange is set.
+// drop decides, from the range start and how many times it was requested, how many bytes
+// are sent before the connection drops, -1 sending the whole range.
+type rangeServer struct {
+	content     []byte
+	ignoreRange bool
+	drop        func(start int64, attempt int) int
+
+	mu       sync.Mutex
+	requests []string
+	attempts map[int64]int
+}
+
+func (s *rangeServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Accept-Ranges", "bytes")
+	if r.Method == http.MethodHead {
+		w.Header().Set("Content-Length", strconv.Itoa(len(s.content)))
+		return
+	}
+
+	rangeHeader := r.Header.Get("Range")
+	s.mu.Lock()
+	s.requests = append(s.requests, rangeHeader)
+	s.mu.Unlock()
+
+	if rangeHeader == "" || s.ignoreRange {
+		w.Header().Set("Content-Length", strconv.Itoa(len(s.content)))
+		w.Write(s.content)
+		return
+	}
+
+	var start, end int64
+	if _, err := fmt.Sscanf(rangeHeader, "bytes=%d-%d", &start, &end); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	s.mu.Lock()
+	attempt := s.attempts[start]
+	s.attempts[start]++
+	s.mu.Unlock()
+
+	body := s.content[start : end+1]
+	w.Header().Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", start, end, len(s.content)))
+	w.Header().Set("Content-Length", strconv.Itoa(len(body)))
+	w.WriteHeader(http.StatusPartialContent)
+
+	// Sending less than the announced length makes the server close the connection
+	if s.drop != nil {
+		if sent := s.drop(start, attempt); sent >= 0 && sent < len(body) {
+			w.Write(body[:sent])
+			return
+		}
+	}
+	w.Write(body)
+}
+
+func (s *rangeServer) requested(rangeHeader string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, request := range s.requests {
+		if request == rangeHeader {
+			return true
+		}
+	}
+	return false
+}
+
+func TestDownloadFileChunked(t *testing.T) {
+	previous := vars.Config()
+	vars.SetConfig(&vars.ConfigDefinition{Download: vars.DownloadDetails{Chunks: 4, ChunkThreshold: 1}})
+	defer func() { vars.SetConfig(previous) }()
+
+	content := make([]byte, 1000)
+	for i := range content {
+		content[i] = byte(i * 7)
+	}
+
+	tests := []struct {
+		name        string
+		ignoreRange bool
+		drop        func(start int64, attempt int) int
+		wantErr     bool
+		// wantRequests are Range headers that must have been sent
+		wantRequests []string
+	}{
+		{
+			name:         "splits the file into ranges",
+			wantRequests: []string{"bytes=0-249", "bytes=250-499", "bytes=500-749", "bytes=750-999"},
+		},
+		{
+			name: "resumes a range after the connection drops",
+			drop: func(start int64, attempt int) int {
+				if start == 250 && attempt == 0 {
+					return 100
+				}
+				return -1
+			},
+			wantRequests: []string{"bytes=250-499", "bytes=350-499"},
+		},
+		{
+			name:         "downloads the whole file when ranges are ignored",
+			ignoreRange:  true,
+			wantRequests: []string{""},
+		},
+		{
+			name: "fails when a range keeps dropping",
+			drop: func(start int64, attempt int) int {
+				if start >= 500 && start < 750 {
+					return 10
+				}
+				return -1
+			},
+			wantErr: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			server := &rangeServer{
+				content:     content,
+				ignoreRange: test.ignoreRange,
+				drop:        test.drop,
+				attempts:    make(map[int64]int),
+			}
+			httpServer := httptest.NewServer(server)
+			defer httpServer.Close()
+
+			dir := t.TempDir()
+			err := DownloadFile(context.Background(), dir, "file.bin", httpServer.URL+"/file.bin", func(int64, int64) {})
+
+			data, readErr := os.ReadFile(filepath.Join(dir, "file.bin"))
+			if test.wantErr {
+				if err == nil {
+					t.Fatal("DownloadFile() succeeded, want an error")
+				}
+				if readErr == nil {
+					t.Error("the partial file was left on disk")
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("DownloadFile() error = %v", err)
+			}
+			if !bytes.Equal(data, content) {
+				t.Errorf("downloaded file differs from the served content")
+			}
+			for _, rangeHeader := range test.wantRequests {
+				if !server.requested(rangeHeader) {
+					t.Errorf("no request with Range %q, got %q", rangeHeader, server.requests)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,224 @@
+package services
+
+import (
+	"bufio"
+	"handheldui/output"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+var (
+	cueFilePattern  = regexp.MustCompile(`(?i)^\s*FILE\s+"?([^"]+?)"?\s+\w+\s*$`)
+	discTagPattern  = regexp.MustCompile(`(?i)\s*\((?:disc|disk|cd)\s*[0-9a-z]+(?:\s+of\s+[0-9]+)?\)`)
+	playableDiscExt = map[string]bool{
+		".cue": true, ".chd": true, ".iso": true, ".pbp": true, ".gdi": true, ".cdi": true, ".cso": true,
+	}
+)
+
+// GroupDiscSets merges the files of a disc based game into one entry. The .bin tracks
+// named after a .cue file join the cue entry, and the "(Disc N)" entries of a title are
+// joined into a multi-disc entry when there are at least two of them. Only disc images
+// are joined by title, unless anyExtension is set, as for repositories of zipped discs.
+// Each set entry keeps its files in "set" and the files a playlist should point to in
+// "discs".
+func GroupDiscSets(items []map[string]interface{}, anyExtension bool) []map[string]interface{} {
+	var grouped []map[string]interface{}
+
+	// Joins the tracks to their cue sheet
+	cueSets := make(map[string]map[string]interface{})
+	for _, item := range items {
+		fileName := item["name"].(string)
+		if strings.EqualFold(path.Ext(fileName), ".cue") {
+			cueSets[strings.ToLower(strings.TrimSuffix(fileName, path.Ext(fileName)))] = newDiscSet(fileName, item)
+		}
+	}
+
+	for _, item := range items {
+		fileName := item["name"].(string)
+		if strings.EqualFold(path.Ext(fileName), ".cue") {
+			continue
+		}
+
+		if set := findCueSet(cueSets, fileName); set != nil {
+			set["set"] = append(set["set"].([]map[string]interface{}), item)
+			continue
+		}
+
+		grouped = append(grouped, item)
+	}
+
+	for _, set := range cueSets {
+		if len(set["set"].([]map[string]interface{})) == 1 {
+			// A cue without tracks is listed as a regular file
+			grouped = append(grouped, set["set"].([]map[string]interface{})[0])
+			continue
+		}
+		set["status"] = DiscSetStatus(set)
+		grouped = append(grouped, set)
+	}
+
+	// Joins the discs of the same title
+	var result []map[string]interface{}
+	discSets := make(map[string]map[string]interface{})
+	discItems := make(map[string][]map[string]interface{})
+	setIndexes := make(map[string]int)
+	for _, item := range grouped {
+		fileName := item["name"].(string)
+		if !discTagPattern.MatchString(path.Base(fileName)) || !(anyExtension || isDiscImage(fileName)) {
+			result = append(result, item)
+			continue
+		}
+
+		setName := discTagPattern.ReplaceAllString(strings.TrimSuffix(fileName, path.Ext(fileName)), "")
+		key := strings.ToLower(setName)
+
+		set, ok := discSets[key]
+		if !ok {
+			set = newDiscSet(setName, item)
+			set["set"] = []map[string]interface{}{}
+			set["discs"] = []string{}
+			discSets[key] = set
+			setIndexes[key] = len(result)
+			result = append(result, set)
+		}
+
+		set["set"] = append(set["set"].([]map[string]interface{}), DiscSetFiles(item)...)
+		set["discs"] = append(set["discs"].([]string), discSetDiscs(item)...)
+		discItems[key] = append(discItems[key], item)
+	}
+
+	// A lone disc is listed as it is
+	for key := range discSets {
+		if len(discItems[key]) > 1 {
+			continue
+		}
+		result[setIndexes[key]] = discItems[key][0]
+		delete(discSets, key)
+	}
+
+	for _, set := range discSets {
+		files := set["set"].([]map[string]interface{})
+		sort.Slice(files, func(i, j int) bool {
+			return files[i]["name"].(string) < files[j]["name"].(string)
+		})
+		sort.Strings(set["discs"].([]string))
+		set["multidisc"] = true
+		set["status"] = DiscSetStatus(set)
+	}
+
+	return result
+}
+
+// isDiscImage checks if a file is a disc image, a joined cue set included
+func isDiscImage(fileName string) bool {
+	return playableDiscExt[strings.ToLower(path.Ext(fileName))]
+}
+
+// newDiscSet creates a set entry, copying the details of its first file
+func newDiscSet(name string, first map[string]interface{}) map[string]interface{} {
+	set := map[string]interface{}{
+		"name":  name,
+		"set":   []map[string]interface{}{first},
+		"discs": []string{first["name"].(string)},
+	}
+
+	for _, key := range []string{"value", "unzip", "collection", "file", "path", "localname", "status"} {
+		set[key] = first[key]
+	}
+
+	return set
+}
+
+// findCueSet returns the cue set whose name prefixes the track name
+func findCueSet(cueSets map[string]map[string]interface{}, fileName string) map[string]interface{} {
+	if len(cueSets) == 0 {
+		return nil
+	}
+
+	stem := strings.ToLower(strings.TrimSuffix(fileName, path.Ext(fileName)))
+	if set, ok := cueSets[stem]; ok {
+		return set
+	}
+
+	// Tracks are usually named "<cue name> (Track N).bin"
+	if index := strings.LastIndex(stem, " (track "); index > 0 {
+		return cueSets[stem[:index]]
+	}
+
+	return nil
+}
+
+// DiscSetFiles returns the files of a set entry, or the entry itself for regular files.
+func DiscSetFiles(item map[string]interface{}) []map[string]interface{} {
+	if files, ok := item["set"].([]map[string]interface{}); ok {
+		return files
+	}
+	return []map[string]interface{}{item}
+}
+
+func discSetDiscs(item map[string]interface{}) []string {
+	if discs, ok := item["discs"].([]string); ok {
+		return discs
+	}
+	return []string{item["name"].(string)}
+}
+
+// DiscSetStatus is present only when every file of the set is present
+func DiscSetStatus(set map[string]interface{}) string {
+	status := StatusPresent
+	for _, item := range set["set"].([]map[string]interface{}) {
+		switch item["status"].(string) {
+		case StatusNew:
+			return StatusNew
+		case StatusDifferent:
+			status = StatusDifferent
+		}
+	}
+	return status
+}
+
+// ReadCueFiles returns the file names referenced by a local cue sheet.
+func ReadCueFiles(cuePath string) ([]string, error) {
+	cueFile, err := os.Open(cuePath)
+	if err != nil {
+		return nil, err
+	}
+	defer cueFile.Close()
+
+	var files []string
+	scanner := bufio.NewScanner(cueFile)
+	for scanner.Scan() {
+		if match := cueFilePattern.FindStringSubmatch(scanner.Text()); match != nil {
+			files = append(files, match[1])
+		}
+	}
+
+	return files, scanner.Err()
+}
+
+// PlayableDiscFile picks the file a frontend should launch for a disc, preferring the
+// extracted contents of an archive.
+func PlayableDiscFile(installed InstalledFile) string {
+	for _, extracted := range installed.Extracted {
+		if playableDiscExt[strings.ToLower(filepath.Ext(extracted))] {
+			return extracted
+		}
+	}
+	return filepath.Base(installed.Path)
+}
+
+// WritePlaylist writes a .m3u playlist that lists the discs of a game.
+func WritePlaylist(destPath, title string, discs []string) (string, error) {
+	playlistPath := filepath.Join(destPath, title+".m3u")
+
+	content := strings.Join(discs, "\n") + "\n"
+	if err := os.WriteFile(playlistPath, []byte(content), 0644); err != nil {
+		return "", output.Errorf("error writing playlist %s: %v", playlistPath, err)
+	}
+
+	return playlistPath, nil
+}
@@ -0,0 +1,270 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"handheldui/helpers/network"
+	"handheldui/output"
+	"handheldui/vars"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Collection entry types
+const (
+	CollectionItem      = "item"
+	CollectionParent    = "parent"
+	CollectionFavorites = "favorites"
+)
+
+const (
+	searchPageSize = 1000
+	// membersMaxAge is how long an expansion is used before it is searched again
+	membersMaxAge = 24 * time.Hour
+)
+
+// Member is an item found inside a parent collection or a favorites list
+type Member struct {
+	Identifier string `json:"identifier"`
+	Title      string `json:"title"`
+}
+
+type searchResponse struct {
+	Response struct {
+		NumFound int      `json:"numFound"`
+		Docs     []Member `json:"docs"`
+	} `json:"response"`
+}
+
+var (
+	// expandedCollections keeps the entries created for each member, so their mirrors and
+	// credentials are found by identifier like the configured ones
+	expandedCollections = make(map[string]vars.CollectionDetails)
+	expandedLock        sync.RWMutex
+)
+
+// RepositoryCollections returns the collections of a repository, replacing the parent and
+// favorites entries with one entry per member item. Members inherit the settings of their
+// entry and are listed inside a folder named after their title. With refresh set, the
+// members are searched again instead of read from the cache.
+func RepositoryCollections(repo vars.PlatformDetails, refresh bool) ([]vars.CollectionDetails, error) {
+	var collections []vars.CollectionDetails
+
+	for _, collection := range repo.Collections {
+		query := memberQuery(collection)
+		if query == "" {
+			collections = append(collections, collection)
+			continue
+		}
+
+		members, err := fetchMembers(collection.Name, query, refresh)
+		if err != nil {
+			return nil, err
+		}
+		collections = append(collections, expandMembers(collection, members)...)
+	}
+
+	return collections, nil
+}
+
+// CachedRepositoryCollections expands the collections of a repository like
+// RepositoryCollections, from the cached members only, however old. The entries whose
+// members were never searched are returned as missing.
+func CachedRepositoryCollections(repo vars.PlatformDetails) (collections []vars.CollectionDetails, missing []string) {
+	for _, collection := range repo.Collections {
+		if memberQuery(collection) == "" {
+			collections = append(collections, collection)
+			continue
+		}
+
+		members, ok := cachedMembers(collection.Name)
+		if !ok {
+			missing = append(missing, collection.Name)
+			continue
+		}
+		collections = append(collections, expandMembers(collection, members)...)
+	}
+
+	return collections, missing
+}
+
+// expandMembers creates the entries of the members of a parent or favorites entry
+func expandMembers(collection vars.CollectionDetails, members []Member) []vars.CollectionDetails {
+	var collections []vars.CollectionDetails
+
+	used := make(map[string]bool)
+	for _, member := range members {
+		expanded := collection
+		expanded.Type = CollectionItem
+		expanded.Name = member.Identifier
+		expanded.Parent = collection.Name
+		expanded.Folder = memberFolder(collection.Folder, member, used)
+
+		expandedLock.Lock()
+		expandedCollections[member.Identifier] = expanded
+		expandedLock.Unlock()
+
+		collections = append(collections, expanded)
+	}
+
+	return collections
+}
+
+// memberQuery returns the search query listing the members of an entry, empty for items
+func memberQuery(collection vars.CollectionDetails) string {
+	switch collection.Type {
+	case CollectionParent:
+		return fmt.Sprintf("collection:(%s)", collection.Name)
+	case CollectionFavorites:
+		return fmt.Sprintf("collection:(fav-%s)", strings.TrimPrefix(collection.Name, "fav-"))
+	}
+	return ""
+}
+
+// memberFolder names the folder of a member after its title, using the identifier when
+// the title is missing or already taken
+func memberFolder(parent string, member Member, used map[string]bool) string {
+	name := strings.TrimSpace(strings.ReplaceAll(member.Title, "/", "-"))
+	if name == "" || used[name] {
+		name = member.Identifier
+	}
+	used[name] = true
+
+	if parent != "" {
+		return parent + "/" + name
+	}
+	return name
+}
+
+// findCollection returns the configured or expanded entry of a collection
+func findCollection(name string) (vars.CollectionDetails, bool) {
+	if vars.Config() != nil {
+		for _, repo := range vars.Config().Repositories {
+			for _, collection := range repo.Collections {
+				if collection.Name == name {
+					return collection, true
+				}
+			}
+		}
+	}
+
+	expandedLock.RLock()
+	defer expandedLock.RUnlock()
+	collection, ok := expandedCollections[name]
+	return collection, ok
+}
+
+// Returns the cache file path of the members of a parent entry
+func getMembersCacheFilePath(name string) string {
+	return filepath.Join(".cache", "archive_metadata", fmt.Sprintf("members_%s.json", name))
+}
+
+// cachedMembers reads the members of an entry from the cache, without searching them
+func cachedMembers(name string) ([]Member, bool) {
+	cacheLock.RLock()
+	data, err := os.ReadFile(getMembersCacheFilePath(name))
+	cacheLock.RUnlock()
+	if err != nil {
+		return nil, false
+	}
+
+	var members []Member
+	if err := json.Unmarshal(data, &members); err != nil {
+		return nil, false
+	}
+	return members, true
+}
+
+// fetchMembers returns the cached members of an entry, searching them when the cache is
+// missing, too old or when refresh is set. An outdated cache is kept when the search fails.
+func fetchMembers(name, query string, refresh bool) ([]Member, error) {
+	cacheFilePath := getMembersCacheFilePath(name)
+
+	var cached []Member
+	cacheLock.RLock()
+	data, err := os.ReadFile(cacheFilePath)
+	cacheLock.RUnlock()
+	if err == nil && json.Unmarshal(data, &cached) == nil && !refresh {
+		if info, err := os.Stat(cacheFilePath); err == nil && time.Since(info.ModTime()) < membersMaxAge {
+			return cached, nil
+		}
+	}
+
+	members, err := searchMembers(name, query)
+	if err != nil {
+		if cached != nil && !refresh {
+			output.Errorf("Error searching members of %s, using the cache: %v", name, err)
+			return cached, nil
+		}
+		return nil, err
+	}
+
+	data, err = json.Marshal(members)
+	if err != nil {
+		return nil, output.Errorf("error encoding members of %s: %v", name, err)
+	}
+
+	cacheLock.Lock()
+	defer cacheLock.Unlock()
+
+	if err := os.MkdirAll(filepath.Dir(cacheFilePath), os.ModePerm); err != nil {
+		return nil, output.Errorf("error creating cache directories: %v", err)
+	}
+	if err := os.WriteFile(cacheFilePath, data, 0644); err != nil {
+		return nil, output.Errorf("error writing members cache: %v", err)
+	}
+
+	return members, nil
+}
+
+// searchURL returns the search API of the configured archive, which sits beside its
+// download folder, like https://archive.org/advancedsearch.php for https://archive.org/download
+func searchURL() string {
+	base := strings.TrimSuffix(vars.Config().URLs.Archive, "/")
+	return strings.TrimSuffix(base, "/download") + "/advancedsearch.php"
+}
+
+// searchMembers pages through the search API results of a query
+func searchMembers(name, query string) ([]Member, error) {
+	ctx := withCollectionAuth(context.Background(), name)
+
+	var members []Member
+	for page := 1; ; page++ {
+		params := url.Values{}
+		params.Set("q", query)
+		params.Add("fl[]", "identifier")
+		params.Add("fl[]", "title")
+		params.Set("sort[]", "identifier asc")
+		params.Set("rows", fmt.Sprint(searchPageSize))
+		params.Set("page", fmt.Sprint(page))
+		params.Set("output", "json")
+
+		link := searchURL() + "?" + params.Encode()
+		resp, err := network.GetWithContext(ctx, link)
+		if err != nil {
+			return nil, output.Errorf("error searching members of %s: %v", name, err)
+		}
+
+		var result searchResponse
+		if resp.StatusCode != http.StatusOK {
+			err = &StatusError{URL: link, Status: resp.Status, Code: resp.StatusCode}
+		} else {
+			err = json.NewDecoder(resp.Body).Decode(&result)
+		}
+		resp.Body.Close()
+		if err != nil {
+			return nil, output.Errorf("error searching members of %s: %w", name, err)
+		}
+
+		members = append(members, result.Response.Docs...)
+		if len(result.Response.Docs) < searchPageSize || len(members) >= result.Response.NumFound {
+			return members, nil
+		}
+	}
+}
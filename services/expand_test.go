@@ -0,0 +1,59 @@
+package services
+
+import (
+	"encoding/json"
+	"handheldui/vars"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestSearchURL(t *testing.T) {
+	tests := []struct {
+		archive string
+		want    string
+	}{
+		{"https://archive.org/download", "https://archive.org/advancedsearch.php"},
+		{"https://archive.org/download/", "https://archive.org/advancedsearch.php"},
+		{"http://192.168.0.10/ia/download", "http://192.168.0.10/ia/advancedsearch.php"},
+		{"http://192.168.0.10/ia", "http://192.168.0.10/ia/advancedsearch.php"},
+	}
+
+	previous := vars.Config()
+	defer func() { vars.SetConfig(previous) }()
+
+	for _, test := range tests {
+		vars.SetConfig(&vars.ConfigDefinition{URLs: vars.URLDetails{Archive: test.archive}})
+		if got := searchURL(); got != test.want {
+			t.Errorf("searchURL() with archive %q = %q, want %q", test.archive, got, test.want)
+		}
+	}
+}
+
+// TestSearchMembers searches the members of a collection on the configured archive
+func TestSearchMembers(t *testing.T) {
+	archive := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/advancedsearch.php" {
+			http.NotFound(w, r)
+			return
+		}
+
+		var result searchResponse
+		result.Response.NumFound = 2
+		result.Response.Docs = []Member{{Identifier: "first", Title: "First"}, {Identifier: "second", Title: "Second"}}
+		json.NewEncoder(w).Encode(result)
+	}))
+	defer archive.Close()
+
+	previous := vars.Config()
+	vars.SetConfig(&vars.ConfigDefinition{URLs: vars.URLDetails{Archive: archive.URL + "/download"}})
+	defer func() { vars.SetConfig(previous) }()
+
+	members, err := searchMembers("parent", "collection:parent")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(members) != 2 || members[0].Identifier != "first" || members[1].Identifier != "second" {
+		t.Errorf("searchMembers() = %v, want first and second", members)
+	}
+}
@@ -0,0 +1,303 @@
+package services
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"handheldui/helpers/markdown"
+	"handheldui/output"
+	"handheldui/vars"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Export types
+const (
+	ExportGamelist = "gamelist"
+	ExportM3U      = "m3u"
+	ExportJSON     = "json"
+)
+
+// ExportEntry is one installed game or file as written by the exporters
+type ExportEntry struct {
+	Path     string `json:"path"`
+	Name     string `json:"name"`
+	Key      string `json:"key,omitempty"`
+	Rank     string `json:"rank,omitempty"`
+	Overview string `json:"overview,omitempty"`
+	Image    string `json:"image,omitempty"`
+}
+
+// rankRatings converts the database ranks to the 0 to 1 rating of gamelist.xml
+var rankRatings = map[string]string{
+	"PLATINUM": "1.0",
+	"GOLD":     "0.8",
+	"SILVER":   "0.6",
+	"BRONZE":   "0.4",
+	"FAULTY":   "0.2",
+}
+
+var (
+	gameOverviews      = make(map[string]string)
+	gameOverviewsMutex sync.Mutex
+)
+
+// fetchOverviewCached returns the overview of a game, fetched once per session. The
+// lock is not held during the fetch, so other games aren't kept waiting.
+func fetchOverviewCached(gameKey string) string {
+	gameOverviewsMutex.Lock()
+	overview, ok := gameOverviews[gameKey]
+	gameOverviewsMutex.Unlock()
+	if ok {
+		return overview
+	}
+
+	overview, err := FetchGameOverview(gameKey)
+	if err != nil {
+		output.Errorf("Error fetching overview of %s: %v", gameKey, err)
+	}
+
+	overview = strings.TrimSpace(overview)
+
+	gameOverviewsMutex.Lock()
+	gameOverviews[gameKey] = overview
+	gameOverviewsMutex.Unlock()
+	return overview
+}
+
+// ExportLists writes the lists configured for a repository from its installed files.
+// With afterDownload set, only the exports marked to run after each download are written.
+func ExportLists(repoKey string, repo vars.PlatformDetails, afterDownload bool) error {
+	var exports []vars.ExportDetails
+	for _, export := range repo.Exports {
+		if !afterDownload || export.AfterDownload {
+			exports = append(exports, export)
+		}
+	}
+
+	if len(exports) == 0 {
+		return nil
+	}
+
+	entries, err := buildExportEntries(repoKey, repo)
+	if err != nil {
+		return err
+	}
+
+	for _, export := range exports {
+		exportPath := exportFilePath(export, repo.Path)
+
+		switch export.Type {
+		case ExportGamelist:
+			err = writeGamelist(exportPath, entries)
+		case ExportM3U:
+			err = writeM3U(exportPath, entries)
+		case ExportJSON:
+			err = writeJSONIndex(exportPath, entries)
+		default:
+			err = output.Errorf("unknown export type %s", export.Type)
+		}
+
+		if err != nil {
+			return output.Errorf("error writing %s: %v", exportPath, err)
+		}
+
+		output.Printf("Exported %d entries to %s\n", len(entries), exportPath)
+	}
+
+	return nil
+}
+
+// exportFilePath returns where an export is written, relative paths being inside the repository
+func exportFilePath(export vars.ExportDetails, repoPath string) string {
+	exportPath := export.Path
+	if exportPath == "" {
+		switch export.Type {
+		case ExportGamelist:
+			exportPath = "gamelist.xml"
+		case ExportM3U:
+			exportPath = filepath.Base(repoPath) + ".m3u"
+		default:
+			exportPath = "index.json"
+		}
+	}
+
+	if !filepath.IsAbs(exportPath) {
+		exportPath = filepath.Join(repoPath, exportPath)
+	}
+
+	return exportPath
+}
+
+// buildExportEntries lists the installed files still on disk, with their database details
+func buildExportEntries(repoKey string, repo vars.PlatformDetails) ([]ExportEntry, error) {
+	manifest, err := LoadManifest(repoKey)
+	if err != nil {
+		return nil, err
+	}
+
+	var games []map[string]interface{}
+	if repo.System != "" {
+		games, err = fetchSystemGames(repo.System)
+		if err != nil {
+			// Exports still work without the database details
+			output.Errorf("Error fetching games of %s: %v", repo.System, err)
+		}
+	}
+
+	artwork, hasArtwork := vars.Config().Artwork[vars.CurrentPlatform()]
+
+	var entries []ExportEntry
+	for _, installed := range manifest {
+		for _, filePath := range installed.Paths() {
+			if !isGameFile(filePath, repo.ExtList) {
+				continue
+			}
+			if _, err := os.Stat(filePath); err != nil {
+				continue
+			}
+
+			fileName := filepath.Base(filePath)
+			entry := ExportEntry{
+				Path: filePath,
+				Name: ParseRomTags(fileName).Title,
+			}
+			if entry.Name == "" {
+				entry.Name = strings.TrimSuffix(fileName, filepath.Ext(fileName))
+			}
+
+			if game := MatchGame(games, fileName); game != nil {
+				entry.Key, _ = game["key"].(string)
+				entry.Rank, _ = game["rank"].(string)
+				if name, ok := game["name"].(string); ok {
+					entry.Name = name
+				}
+				entry.Overview = fetchOverviewCached(entry.Key)
+			}
+
+			if hasArtwork {
+				if imagePath := ArtworkPath(filePath, artwork); fileExists(imagePath) {
+					entry.Image = imagePath
+				}
+			}
+
+			entries = append(entries, entry)
+		}
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		return strings.ToLower(entries[i].Path) < strings.ToLower(entries[j].Path)
+	})
+
+	return entries, nil
+}
+
+type gamelistXML struct {
+	XMLName xml.Name          `xml:"gameList"`
+	Games   []gamelistGameXML `xml:"game"`
+}
+
+type gamelistGameXML struct {
+	Path   string `xml:"path"`
+	Name   string `xml:"name"`
+	Desc   string `xml:"desc,omitempty"`
+	Image  string `xml:"image,omitempty"`
+	Rating string `xml:"rating,omitempty"`
+}
+
+// writeGamelist writes an EmulationStation gamelist.xml, with paths relative to its folder
+func writeGamelist(exportPath string, entries []ExportEntry) error {
+	baseDir := filepath.Dir(exportPath)
+
+	var gamelist gamelistXML
+	for _, entry := range entries {
+		game := gamelistGameXML{
+			Path:   relativeExportPath(baseDir, entry.Path),
+			Name:   entry.Name,
+			Desc:   markdown.MarkdownToPlaintext(entry.Overview),
+			Rating: rankRatings[entry.Rank],
+		}
+		if entry.Image != "" {
+			game.Image = relativeExportPath(baseDir, entry.Image)
+		}
+		gamelist.Games = append(gamelist.Games, game)
+	}
+
+	data, err := xml.MarshalIndent(gamelist, "", "\t")
+	if err != nil {
+		return err
+	}
+
+	return writeExportFile(exportPath, append([]byte(xml.Header), append(data, '\n')...))
+}
+
+// writeM3U writes an extended playlist, with paths relative to its folder
+func writeM3U(exportPath string, entries []ExportEntry) error {
+	baseDir := filepath.Dir(exportPath)
+
+	var builder strings.Builder
+	builder.WriteString("#EXTM3U\n")
+	for _, entry := range entries {
+		builder.WriteString(fmt.Sprintf("#EXTINF:-1,%s\n", entry.Name))
+		builder.WriteString(relativeExportPath(baseDir, entry.Path) + "\n")
+	}
+
+	return writeExportFile(exportPath, []byte(builder.String()))
+}
+
+// writeJSONIndex writes the entries as a JSON array, with paths relative to its folder
+func writeJSONIndex(exportPath string, entries []ExportEntry) error {
+	baseDir := filepath.Dir(exportPath)
+
+	index := make([]ExportEntry, 0, len(entries))
+	for _, entry := range entries {
+		entry.Path = relativeExportPath(baseDir, entry.Path)
+		if entry.Image != "" {
+			entry.Image = relativeExportPath(baseDir, entry.Image)
+		}
+		index = append(index, entry)
+	}
+
+	data, err := json.MarshalIndent(index, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return writeExportFile(exportPath, append(data, '\n'))
+}
+
+// relativeExportPath returns a "./" path relative to the export folder when possible
+func relativeExportPath(baseDir, filePath string) string {
+	relative, err := filepath.Rel(baseDir, filePath)
+	if err != nil || strings.HasPrefix(relative, "..") {
+		return filePath
+	}
+	return "./" + filepath.ToSlash(relative)
+}
+
+// writeExportFile replaces an export file through a temporary file, so the frontend never reads a partial list
+func writeExportFile(exportPath string, data []byte) error {
+	if err := os.MkdirAll(filepath.Dir(exportPath), 0755); err != nil {
+		return err
+	}
+
+	tempPath := exportPath + ".tmp"
+	if err := os.WriteFile(tempPath, data, 0644); err != nil {
+		return err
+	}
+
+	if err := os.Rename(tempPath, exportPath); err != nil {
+		os.Remove(tempPath)
+		return err
+	}
+
+	return nil
+}
+
+func fileExists(filePath string) bool {
+	_, err := os.Stat(filePath)
+	return err == nil
+}
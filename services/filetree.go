@@ -0,0 +1,95 @@
+package services
+
+import (
+	"sort"
+	"strings"
+)
+
+// FileTree represents a folder built from the file names of a collection listing.
+type FileTree struct {
+	Name    string
+	Path    string
+	Parent  *FileTree
+	Folders []*FileTree
+	Items   []map[string]interface{}
+}
+
+// BuildFileTree splits the "name" of each item on "/" and groups the items into folders.
+func BuildFileTree(items []map[string]interface{}) *FileTree {
+	root := &FileTree{}
+	root.Add(items)
+	return root
+}
+
+// Add places the items into the folders of their names, creating the missing ones, and
+// sorts the tree again. Items are named from the root of the tree.
+func (t *FileTree) Add(items []map[string]interface{}) {
+	folders := make(map[string]*FileTree)
+
+	for _, item := range items {
+		name := item["name"].(string)
+		dir := ""
+		if i := strings.LastIndex(name, "/"); i >= 0 {
+			dir = name[:i]
+		}
+
+		folder, ok := folders[dir]
+		if !ok {
+			folder = t.Folder(dir)
+			folders[dir] = folder
+		}
+		folder.Items = append(folder.Items, item)
+	}
+
+	t.sort()
+}
+
+// Folder returns the folder at the given path, created with its parents when missing.
+func (t *FileTree) Folder(folderPath string) *FileTree {
+	parent := t
+	for _, part := range strings.Split(folderPath, "/") {
+		if part == "" {
+			continue
+		}
+
+		var folder *FileTree
+		for _, existing := range parent.Folders {
+			if existing.Name == part {
+				folder = existing
+				break
+			}
+		}
+		if folder == nil {
+			folder = &FileTree{
+				Name:   part,
+				Path:   strings.TrimPrefix(parent.Path+"/"+part, "/"),
+				Parent: parent,
+			}
+			parent.Folders = append(parent.Folders, folder)
+		}
+		parent = folder
+	}
+	return parent
+}
+
+func (t *FileTree) sort() {
+	sort.Slice(t.Folders, func(i, j int) bool {
+		return t.Folders[i].Name < t.Folders[j].Name
+	})
+	sort.Slice(t.Items, func(i, j int) bool {
+		return t.Items[i]["name"].(string) < t.Items[j]["name"].(string)
+	})
+
+	for _, folder := range t.Folders {
+		folder.sort()
+	}
+}
+
+// AllItems returns the items of the folder and of all its subfolders.
+func (t *FileTree) AllItems() []map[string]interface{} {
+	var items []map[string]interface{}
+	for _, folder := range t.Folders {
+		items = append(items, folder.AllItems()...)
+	}
+	return append(items, t.Items...)
+}
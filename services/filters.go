@@ -0,0 +1,53 @@
+package services
+
+import (
+	"handheldui/vars"
+)
+
+// derivativePatterns match the files archive.org generates for every item
+var derivativePatterns = []string{
+	"*_meta.xml",
+	"*_meta.sqlite",
+	"*_files.xml",
+	"*_reviews.xml",
+	"*.torrent",
+	"*_thumb.jpg",
+	"*_itemimage.jpg",
+	"*_spectrogram.png",
+	"*.afpk",
+	"*_djvu.txt",
+	"*_djvu.xml",
+	"*_chocr.html.gz",
+	"*_hocr.html",
+	"*_hocr_pageindex.json.gz",
+	"*_hocr_searchtext.txt.gz",
+	"*_page_numbers.json",
+	"*_scandata.xml",
+}
+
+// IsFileIncluded applies the collection filters to a file from its metadata.
+// Include patterns keep only the matching files, exclude patterns always win.
+func IsFileIncluded(file File, collection vars.CollectionDetails) bool {
+	if collection.OriginalOnly && file.Source != "original" {
+		return false
+	}
+
+	if collection.HideDerivatives && matchesAny(derivativePatterns, file.Name) {
+		return false
+	}
+
+	if len(collection.Include) > 0 && !matchesAny(collection.Include, file.Name) {
+		return false
+	}
+
+	return !matchesAny(collection.Exclude, file.Name)
+}
+
+func matchesAny(patterns []string, fileName string) bool {
+	for _, pattern := range patterns {
+		if MatchPattern(pattern, fileName) {
+			return true
+		}
+	}
+	return false
+}
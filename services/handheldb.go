@@ -3,14 +3,14 @@ package services
 import (
 	"encoding/json"
 	"fmt"
+	"handheldui/helpers/network"
 	"handheldui/output"
+	"handheldui/vars"
 	"io"
 	"net/http"
 	"strings"
 )
 
-const baseURL = "https://handheld-database.github.io/handheld-database"
-
 // GetRankColor returns the color for a given rank.
 func GetRankColor(key string) string {
 	colors := map[string]string{
@@ -25,7 +25,7 @@ func GetRankColor(key string) string {
 
 // FetchPlatformsIndex fetches the index of platforms.
 func FetchPlatformsIndex() ([]string, error) {
-	resp, err := http.Get(fmt.Sprintf("%s/platforms/index.json", baseURL))
+	resp, err := network.Get(fmt.Sprintf("%s/platforms/index.json", vars.Config().URLs.Database))
 	if err != nil {
 		return nil, output.Errorf("error fetching popular platforms: %v", err)
 	}
@@ -48,7 +48,7 @@ func FetchPlatformsIndex() ([]string, error) {
 
 // FetchPlatform fetches data for a given platform.
 func FetchPlatform(platformKey string) (map[string]interface{}, error) {
-	resp, err := http.Get(fmt.Sprintf("%s/platforms/%s/index.json", baseURL, platformKey))
+	resp, err := network.Get(fmt.Sprintf("%s/platforms/%s/index.json", vars.Config().URLs.Database, platformKey))
 	if err != nil {
 		return nil, output.Errorf("error fetching systems from %s: %v", platformKey, err)
 	}
@@ -68,7 +68,7 @@ func FetchPlatform(platformKey string) (map[string]interface{}, error) {
 
 // FetchGames fetches games for a given platform and system.
 func FetchGames(platformKey, systemKey string) ([]map[string]interface{}, error) {
-	resp, err := http.Get(fmt.Sprintf("%s/platforms/%s/systems/%s/index.json", baseURL, platformKey, systemKey))
+	resp, err := network.Get(fmt.Sprintf("%s/platforms/%s/systems/%s/index.json", vars.Config().URLs.Database, platformKey, systemKey))
 	if err != nil {
 		return nil, output.Errorf("error fetching games from %s/%s: %v", platformKey, systemKey, err)
 	}
@@ -91,7 +91,7 @@ func FetchGames(platformKey, systemKey string) ([]map[string]interface{}, error)
 
 // FetchTesters fetches testers for a given platform and system.
 func FetchTesters(platformKey, systemKey, gameKey string) ([]string, error) {
-	resp, err := http.Get(fmt.Sprintf("%s/platforms/%s/systems/%s/%s/%s.json", baseURL, platformKey, systemKey, gameKey, gameKey))
+	resp, err := network.Get(fmt.Sprintf("%s/platforms/%s/systems/%s/%s/%s.json", vars.Config().URLs.Database, platformKey, systemKey, gameKey, gameKey))
 	if err != nil {
 		return nil, output.Errorf("error fetching game details from %s/%s/%s: %v", platformKey, systemKey, gameKey, err)
 	}
@@ -114,7 +114,7 @@ func FetchTesters(platformKey, systemKey, gameKey string) ([]string, error) {
 
 // FetchGameDetails fetches details for a given game.
 func FetchGameDetails(platformKey, systemKey, gameKey string) (map[string]interface{}, error) {
-	resp, err := http.Get(fmt.Sprintf("%s/platforms/%s/systems/%s/%s/%s.json", baseURL, platformKey, systemKey, gameKey, gameKey))
+	resp, err := network.Get(fmt.Sprintf("%s/platforms/%s/systems/%s/%s/%s.json", vars.Config().URLs.Database, platformKey, systemKey, gameKey, gameKey))
 	if err != nil {
 		return nil, output.Errorf("error fetching game details from %s/%s/%s: %v", platformKey, systemKey, gameKey, err)
 	}
@@ -133,8 +133,8 @@ func FetchGameDetails(platformKey, systemKey, gameKey string) (map[string]interf
 
 // FetchGameOverview fetches the overview for a given game.
 func FetchGameOverview(gameKey string) (string, error) {
-	output.Printf("%s/commons/overviews/%s.overview.md", baseURL, gameKey)
-	resp, err := http.Get(fmt.Sprintf("%s/commons/overviews/%s.overview.md", baseURL, gameKey))
+	output.Printf("%s/commons/overviews/%s.overview.md", vars.Config().URLs.Database, gameKey)
+	resp, err := network.Get(fmt.Sprintf("%s/commons/overviews/%s.overview.md", vars.Config().URLs.Database, gameKey))
 	if err != nil {
 		return "", output.Errorf("error fetching game overview: %v", err)
 	}
@@ -154,7 +154,7 @@ func FetchGameOverview(gameKey string) (string, error) {
 
 // FetchGameMarkdown fetches the markdown content for a given game.
 func FetchGameMarkdown(platformKey, systemKey, gameKey, tester string) (string, error) {
-	resp, err := http.Get(fmt.Sprintf("%s/platforms/%s/systems/%s/%s/%s.%s.md", baseURL, platformKey, systemKey, gameKey, gameKey, tester))
+	resp, err := network.Get(fmt.Sprintf("%s/platforms/%s/systems/%s/%s/%s.%s.md", vars.Config().URLs.Database, platformKey, systemKey, gameKey, gameKey, tester))
 	if err != nil {
 		return "", output.Errorf("error fetching game markdown from %s/%s/%s: %v", platformKey, systemKey, gameKey, err)
 	}
@@ -196,7 +196,7 @@ func FilterGames(games []map[string]interface{}, searchTerm, rankFilter string)
 
 // FetchCollaborators fetches the list of collaborators.
 func FetchCollaborators() (map[string]interface{}, error) {
-	resp, err := http.Get(fmt.Sprintf("%s/commons/collaborators/collaborators.json", baseURL))
+	resp, err := network.Get(fmt.Sprintf("%s/commons/collaborators/collaborators.json", vars.Config().URLs.Database))
 	if err != nil {
 		return nil, output.Errorf("error fetching collaborators: %v", err)
 	}
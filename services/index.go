@@ -0,0 +1,489 @@
+package services
+
+import (
+	"bufio"
+	"container/heap"
+	"encoding/binary"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// The index of a collection listing is a single file holding the files sorted by name:
+//
+//	header:  magic, collection, mirror base
+//	records: name, source, size, md5, sha1 (strings prefixed by their uvarint length)
+//	footer:  offset of the first record of each page, record count, footer offset
+//
+// Pages let a list read only the records it shows.
+const (
+	indexMagic    = "HDBIDX1\n"
+	IndexPageSize = 64
+	// indexRunSize is how many files are sorted in memory before being written to a run
+	indexRunSize = 10000
+)
+
+// FileIndex is the on-disk, sorted listing of a collection
+type FileIndex struct {
+	path       string
+	collection string
+	base       string
+	count      int
+	dataStart  int64
+	pages      []int64
+}
+
+// Returns the index file path specific to the given name
+func getIndexFilePath(name string) string {
+	return filepath.Join(".cache", "archive_metadata", fmt.Sprintf("files_%s.idx", name))
+}
+
+// OpenIndex reads the header and page table of an index.
+func OpenIndex(path string) (*FileIndex, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	reader := bufio.NewReader(file)
+	magic := make([]byte, len(indexMagic))
+	if _, err := io.ReadFull(reader, magic); err != nil || string(magic) != indexMagic {
+		return nil, fmt.Errorf("%s is not a listing index", path)
+	}
+
+	index := &FileIndex{path: path}
+	if index.collection, err = readIndexString(reader); err != nil {
+		return nil, fmt.Errorf("error reading index header: %w", err)
+	}
+	if index.base, err = readIndexString(reader); err != nil {
+		return nil, fmt.Errorf("error reading index header: %w", err)
+	}
+	index.dataStart = int64(len(indexMagic)) + uvarintLen(len(index.collection)) + int64(len(index.collection)) + uvarintLen(len(index.base)) + int64(len(index.base))
+
+	info, err := file.Stat()
+	if err != nil {
+		return nil, err
+	}
+
+	trailer := make([]byte, 16)
+	if _, err := file.ReadAt(trailer, info.Size()-16); err != nil {
+		return nil, fmt.Errorf("error reading index footer: %w", err)
+	}
+	index.count = int(binary.LittleEndian.Uint64(trailer[:8]))
+	footerStart := int64(binary.LittleEndian.Uint64(trailer[8:]))
+
+	pageCount := (index.count + IndexPageSize - 1) / IndexPageSize
+	if footerStart < index.dataStart || footerStart+int64(pageCount)*8+16 != info.Size() {
+		return nil, fmt.Errorf("index %s is truncated", path)
+	}
+
+	table := make([]byte, pageCount*8)
+	if _, err := file.ReadAt(table, footerStart); err != nil {
+		return nil, fmt.Errorf("error reading index pages: %w", err)
+	}
+	index.pages = make([]int64, pageCount)
+	for i := range index.pages {
+		index.pages[i] = int64(binary.LittleEndian.Uint64(table[i*8:]))
+	}
+
+	return index, nil
+}
+
+// Len returns how many files the collection has
+func (ix *FileIndex) Len() int {
+	return ix.count
+}
+
+// Collection returns the identifier of the indexed collection
+func (ix *FileIndex) Collection() string {
+	return ix.collection
+}
+
+// Page returns the files of a page, IndexPageSize files at most.
+func (ix *FileIndex) Page(page int) ([]File, error) {
+	if page < 0 || page >= len(ix.pages) {
+		return nil, nil
+	}
+
+	count := IndexPageSize
+	if remaining := ix.count - page*IndexPageSize; remaining < count {
+		count = remaining
+	}
+
+	var files []File
+	err := ix.read(ix.pages[page], count, func(file File) error {
+		files = append(files, file)
+		return nil
+	})
+	return files, err
+}
+
+// Each calls fn with every file, in name order, stopping at the first error.
+func (ix *FileIndex) Each(fn func(File) error) error {
+	return ix.read(ix.dataStart, ix.count, fn)
+}
+
+// Find looks for a file by name, reading only the pages needed by a binary search.
+func (ix *FileIndex) Find(name string) (File, bool, error) {
+	var searchErr error
+	page := sort.Search(len(ix.pages), func(i int) bool {
+		var first File
+		err := ix.read(ix.pages[i], 1, func(file File) error {
+			first = file
+			return nil
+		})
+		if err != nil {
+			searchErr = err
+			return true
+		}
+		return first.Name > name
+	}) - 1
+	if searchErr != nil || page < 0 {
+		return File{}, false, searchErr
+	}
+
+	files, err := ix.Page(page)
+	if err != nil {
+		return File{}, false, err
+	}
+	for _, file := range files {
+		if file.Name == name {
+			return file, true, nil
+		}
+	}
+	return File{}, false, nil
+}
+
+// read decodes count records starting at offset
+func (ix *FileIndex) read(offset int64, count int, fn func(File) error) error {
+	file, err := os.Open(ix.path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	if _, err := file.Seek(offset, io.SeekStart); err != nil {
+		return err
+	}
+
+	reader := bufio.NewReader(file)
+	for i := 0; i < count; i++ {
+		record, err := readIndexRecord(reader)
+		if err != nil {
+			return fmt.Errorf("error reading index %s: %w", ix.path, err)
+		}
+		record.URL = mirrorFileURL(ix.base, ix.collection, record.Name)
+		if err := fn(record); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// buildIndex streams a _files.xml listing into a sorted index. Files are sorted in runs
+// of indexRunSize and the runs are merged, so only one run is held in memory.
+func buildIndex(path, collection, base string, listing io.Reader) (*FileIndex, error) {
+	if err := os.MkdirAll(filepath.Dir(path), os.ModePerm); err != nil {
+		return nil, err
+	}
+
+	var runs []*os.File
+	defer func() {
+		for _, run := range runs {
+			run.Close()
+			os.Remove(run.Name())
+		}
+	}()
+
+	run := make([]File, 0, indexRunSize)
+	decoder := xml.NewDecoder(listing)
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("error decoding listing: %w", err)
+		}
+
+		element, ok := token.(xml.StartElement)
+		if !ok || element.Name.Local != "file" {
+			continue
+		}
+
+		var file File
+		if err := decoder.DecodeElement(&file, &element); err != nil {
+			return nil, fmt.Errorf("error decoding listing: %w", err)
+		}
+		run = append(run, file)
+
+		if len(run) == indexRunSize {
+			runFile, err := writeIndexRun(filepath.Dir(path), run)
+			if err != nil {
+				return nil, err
+			}
+			runs = append(runs, runFile)
+			run = run[:0]
+		}
+	}
+
+	sortFiles(run)
+	sources := []recordSource{&sliceSource{files: run}}
+	for _, runFile := range runs {
+		if _, err := runFile.Seek(0, io.SeekStart); err != nil {
+			return nil, err
+		}
+		sources = append(sources, &runSource{reader: bufio.NewReader(runFile)})
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), "index-*.tmp")
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(tmp.Name())
+
+	if err := writeIndex(tmp, collection, base, sources); err != nil {
+		tmp.Close()
+		return nil, err
+	}
+	if err := tmp.Close(); err != nil {
+		return nil, err
+	}
+
+	cacheLock.Lock()
+	err = os.Rename(tmp.Name(), path)
+	cacheLock.Unlock()
+	if err != nil {
+		return nil, err
+	}
+
+	return OpenIndex(path)
+}
+
+func sortFiles(files []File) {
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].Name < files[j].Name
+	})
+}
+
+// writeIndexRun sorts a run and writes it to a temporary file
+func writeIndexRun(dir string, run []File) (*os.File, error) {
+	sortFiles(run)
+
+	runFile, err := os.CreateTemp(dir, "run-*.tmp")
+	if err != nil {
+		return nil, err
+	}
+
+	writer := bufio.NewWriter(runFile)
+	for _, file := range run {
+		writeIndexRecord(writer, file)
+	}
+	if err := writer.Flush(); err != nil {
+		runFile.Close()
+		os.Remove(runFile.Name())
+		return nil, err
+	}
+
+	return runFile, nil
+}
+
+// writeIndex merges the sorted sources into the index format, dropping repeated names
+func writeIndex(out io.Writer, collection, base string, sources []recordSource) error {
+	writer := &countingWriter{writer: bufio.NewWriter(out)}
+	writer.WriteString(indexMagic)
+	writeIndexString(writer, collection)
+	writeIndexString(writer, base)
+
+	merge := &mergeHeap{}
+	for _, source := range sources {
+		if err := merge.add(source); err != nil {
+			return err
+		}
+	}
+
+	var pages []int64
+	count := 0
+	last := ""
+	for merge.Len() > 0 {
+		top := (*merge)[0]
+		file := top.current
+
+		if count == 0 || file.Name != last {
+			if count%IndexPageSize == 0 {
+				pages = append(pages, writer.written)
+			}
+			writeIndexRecord(writer, file)
+			last = file.Name
+			count++
+		}
+
+		heap.Pop(merge)
+		if err := merge.add(top.source); err != nil {
+			return err
+		}
+	}
+
+	footerStart := writer.written
+	buf := make([]byte, 8)
+	for _, offset := range pages {
+		binary.LittleEndian.PutUint64(buf, uint64(offset))
+		writer.Write(buf)
+	}
+	binary.LittleEndian.PutUint64(buf, uint64(count))
+	writer.Write(buf)
+	binary.LittleEndian.PutUint64(buf, uint64(footerStart))
+	writer.Write(buf)
+
+	if writer.err != nil {
+		return writer.err
+	}
+	return writer.writer.Flush()
+}
+
+// recordSource yields sorted files, io.EOF once exhausted
+type recordSource interface {
+	next() (File, error)
+}
+
+type sliceSource struct {
+	files []File
+}
+
+func (s *sliceSource) next() (File, error) {
+	if len(s.files) == 0 {
+		return File{}, io.EOF
+	}
+	file := s.files[0]
+	s.files = s.files[1:]
+	return file, nil
+}
+
+type runSource struct {
+	reader *bufio.Reader
+}
+
+func (s *runSource) next() (File, error) {
+	return readIndexRecord(s.reader)
+}
+
+type mergeEntry struct {
+	current File
+	source  recordSource
+}
+
+// mergeHeap orders the sources by their current file name
+type mergeHeap []mergeEntry
+
+func (h mergeHeap) Len() int            { return len(h) }
+func (h mergeHeap) Less(i, j int) bool  { return h[i].current.Name < h[j].current.Name }
+func (h mergeHeap) Swap(i, j int)       { h[i], h[j] = h[j], h[i] }
+func (h *mergeHeap) Push(x interface{}) { *h = append(*h, x.(mergeEntry)) }
+func (h *mergeHeap) Pop() interface{} {
+	old := *h
+	entry := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return entry
+}
+
+// add pushes the next file of a source, if any
+func (h *mergeHeap) add(source recordSource) error {
+	file, err := source.next()
+	if err == io.EOF {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	heap.Push(h, mergeEntry{current: file, source: source})
+	return nil
+}
+
+// countingWriter tracks the offset of the next record and keeps the first write error
+type countingWriter struct {
+	writer  *bufio.Writer
+	written int64
+	err     error
+}
+
+func (w *countingWriter) Write(p []byte) (int, error) {
+	if w.err != nil {
+		return 0, w.err
+	}
+	n, err := w.writer.Write(p)
+	w.written += int64(n)
+	w.err = err
+	return n, err
+}
+
+func (w *countingWriter) WriteString(s string) (int, error) {
+	return w.Write([]byte(s))
+}
+
+func writeIndexRecord(writer io.Writer, file File) {
+	writeIndexString(writer, file.Name)
+	writeIndexString(writer, file.Source)
+	buf := make([]byte, binary.MaxVarintLen64)
+	writer.Write(buf[:binary.PutUvarint(buf, uint64(file.Size))])
+	writeIndexString(writer, file.MD5)
+	writeIndexString(writer, file.SHA1)
+}
+
+func readIndexRecord(reader *bufio.Reader) (File, error) {
+	var file File
+	var err error
+	if file.Name, err = readIndexString(reader); err != nil {
+		return file, err
+	}
+	if file.Source, err = readIndexString(reader); err != nil {
+		return file, unexpectedEOF(err)
+	}
+	size, err := binary.ReadUvarint(reader)
+	if err != nil {
+		return file, unexpectedEOF(err)
+	}
+	file.Size = int64(size)
+	if file.MD5, err = readIndexString(reader); err != nil {
+		return file, unexpectedEOF(err)
+	}
+	if file.SHA1, err = readIndexString(reader); err != nil {
+		return file, unexpectedEOF(err)
+	}
+	return file, nil
+}
+
+func writeIndexString(writer io.Writer, value string) {
+	buf := make([]byte, binary.MaxVarintLen64)
+	writer.Write(buf[:binary.PutUvarint(buf, uint64(len(value)))])
+	io.WriteString(writer, value)
+}
+
+func readIndexString(reader *bufio.Reader) (string, error) {
+	length, err := binary.ReadUvarint(reader)
+	if err != nil {
+		return "", err
+	}
+	buf := make([]byte, length)
+	if _, err := io.ReadFull(reader, buf); err != nil {
+		return "", unexpectedEOF(err)
+	}
+	return string(buf), nil
+}
+
+// unexpectedEOF reports a record cut in the middle
+func unexpectedEOF(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
+
+// uvarintLen returns the encoded size of a string length
+func uvarintLen(value int) int64 {
+	buf := make([]byte, binary.MaxVarintLen64)
+	return int64(binary.PutUvarint(buf, uint64(value)))
+}
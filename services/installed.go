@@ -0,0 +1,221 @@
+package services
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"handheldui/output"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// Statuses reported for a remote file compared to the local repository path.
+const (
+	StatusNew       = "new"
+	StatusPresent   = "present"
+	StatusDifferent = "different"
+)
+
+var (
+	manifestLock sync.Mutex
+)
+
+// InstalledFile records a remote file that was installed into a repository.
+type InstalledFile struct {
+	// Name is the remote file name, Path is where it was saved after renaming
+	Name       string   `json:"name"`
+	Collection string   `json:"collection"`
+	Path       string   `json:"path"`
+	Size       int64    `json:"size,omitempty"`
+	MD5        string   `json:"md5,omitempty"`
+	Source     string   `json:"source,omitempty"`
+	Extracted  []string `json:"extracted,omitempty"`
+	Patched    []string `json:"patched,omitempty"`
+}
+
+// Paths returns the files left on disk by an install, the extracted ones when the
+// download was an archive.
+func (i InstalledFile) Paths() []string {
+	if len(i.Extracted) == 0 {
+		return []string{i.Path}
+	}
+
+	var paths []string
+	for _, extracted := range i.Extracted {
+		paths = append(paths, filepath.Join(filepath.Dir(i.Path), extracted))
+	}
+	return paths
+}
+
+// Manifest maps a collection file key to its installed record.
+type Manifest map[string]InstalledFile
+
+// ManifestKey returns the key used to store a remote file in the manifest.
+func ManifestKey(collection, name string) string {
+	return collection + "/" + name
+}
+
+// Returns the manifest file path specific to the given repository
+func getManifestFilePath(repo string) string {
+	return filepath.Join(".cache", "installed", fmt.Sprintf("manifest_%s.json", repo))
+}
+
+// LoadManifest loads the installed files manifest of a repository.
+func LoadManifest(repo string) (Manifest, error) {
+	manifestLock.Lock()
+	defer manifestLock.Unlock()
+
+	return loadManifestFromFile(repo)
+}
+
+func loadManifestFromFile(repo string) (Manifest, error) {
+	manifest := make(Manifest)
+
+	manifestFile, err := os.Open(getManifestFilePath(repo))
+	if err != nil {
+		if os.IsNotExist(err) {
+			// If the file doesn't exist, returns an empty manifest
+			return manifest, nil
+		}
+		return nil, output.Errorf("error opening manifest file: %v", err)
+	}
+	defer manifestFile.Close()
+
+	if err := json.NewDecoder(manifestFile).Decode(&manifest); err != nil {
+		return nil, output.Errorf("error decoding manifest data: %v", err)
+	}
+
+	return manifest, nil
+}
+
+func saveManifestToFile(repo string, manifest Manifest) error {
+	manifestFilePath := getManifestFilePath(repo)
+
+	if err := os.MkdirAll(filepath.Dir(manifestFilePath), os.ModePerm); err != nil {
+		return output.Errorf("error creating manifest directories: %v", err)
+	}
+
+	manifestFile, err := os.Create(manifestFilePath)
+	if err != nil {
+		return output.Errorf("error creating or overwriting manifest file: %v", err)
+	}
+	defer manifestFile.Close()
+
+	if err := json.NewEncoder(manifestFile).Encode(manifest); err != nil {
+		return output.Errorf("error encoding manifest data: %v", err)
+	}
+
+	return nil
+}
+
+// RecordInstall adds or replaces an installed file in the repository manifest.
+func RecordInstall(repo string, installed InstalledFile) error {
+	manifestLock.Lock()
+	defer manifestLock.Unlock()
+
+	manifest, err := loadManifestFromFile(repo)
+	if err != nil {
+		return err
+	}
+
+	manifest[ManifestKey(installed.Collection, installed.Name)] = installed
+
+	return saveManifestToFile(repo, manifest)
+}
+
+// RemoveInstall deletes an installed file from the repository manifest.
+func RemoveInstall(repo string, installed InstalledFile) error {
+	manifestLock.Lock()
+	defer manifestLock.Unlock()
+
+	manifest, err := loadManifestFromFile(repo)
+	if err != nil {
+		return err
+	}
+
+	delete(manifest, ManifestKey(installed.Collection, installed.Name))
+
+	return saveManifestToFile(repo, manifest)
+}
+
+// GetFileStatus compares a remote file with what exists in the destination path under
+// its local name. The contents extracted from it, as recorded in the manifest, also count
+// as the file being present.
+func GetFileStatus(path, localName, collection string, file File, unzip bool, manifest Manifest) string {
+	localPath := filepath.Join(path, localName)
+
+	// Files downloaded inside a folder are tracked by the manifest
+	installed, isInstalled := manifest[ManifestKey(collection, file.Name)]
+	if isInstalled && installed.Path != "" {
+		localPath = installed.Path
+	}
+
+	if info, err := os.Stat(localPath); err == nil && !info.IsDir() {
+		return compareLocalFile(localPath, info.Size(), file)
+	}
+
+	// The archive was extracted and removed, by unzip or a pipeline, so look for its contents
+	if isInstalled && len(installed.Extracted) > 0 {
+		for _, extracted := range installed.Extracted {
+			if _, err := os.Stat(filepath.Join(filepath.Dir(localPath), extracted)); err != nil {
+				return StatusDifferent
+			}
+		}
+		return StatusPresent
+	}
+
+	if !unzip {
+		return StatusNew
+	}
+
+	// Without a manifest entry, a folder named after the archive of an unzip collection
+	// is a good hint
+	stem := strings.TrimSuffix(localName, filepath.Ext(localName))
+	if info, err := os.Stat(filepath.Join(path, stem)); err == nil && info.IsDir() {
+		return StatusPresent
+	}
+
+	return StatusNew
+}
+
+func compareLocalFile(localPath string, localSize int64, file File) string {
+	if file.Size > 0 {
+		if file.Size == localSize {
+			return StatusPresent
+		}
+		return StatusDifferent
+	}
+
+	if file.MD5 != "" {
+		checksum, err := fileMD5(localPath)
+		if err != nil {
+			output.Errorf("error calculating checksum of %s: %v", localPath, err)
+			return StatusDifferent
+		}
+		if strings.EqualFold(checksum, file.MD5) {
+			return StatusPresent
+		}
+		return StatusDifferent
+	}
+
+	return StatusPresent
+}
+
+func fileMD5(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	hash := md5.New()
+	if _, err := io.Copy(hash, f); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
@@ -0,0 +1,55 @@
+package services
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestGetFileStatus(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "game.bin"), []byte("data"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Mkdir(filepath.Join(dir, "album"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	file := File{Name: "game.zip", Size: 4}
+	extracted := Manifest{ManifestKey("roms", "game.zip"): {
+		Name:       "game.zip",
+		Collection: "roms",
+		Path:       filepath.Join(dir, "game.zip"),
+		Extracted:  []string{"game.bin"},
+	}}
+	missing := Manifest{ManifestKey("roms", "game.zip"): {
+		Name:       "game.zip",
+		Collection: "roms",
+		Path:       filepath.Join(dir, "game.zip"),
+		Extracted:  []string{"game.bin", "game.cue"},
+	}}
+
+	tests := []struct {
+		name      string
+		localName string
+		unzip     bool
+		manifest  Manifest
+		want      string
+	}{
+		{name: "not downloaded", localName: "game.zip", manifest: Manifest{}, want: StatusNew},
+		{name: "extracted by unzip", localName: "game.zip", unzip: true, manifest: extracted, want: StatusPresent},
+		{name: "extracted by a pipeline", localName: "game.zip", manifest: extracted, want: StatusPresent},
+		{name: "extracted file removed", localName: "game.zip", manifest: missing, want: StatusDifferent},
+		{name: "folder of an unzip collection", localName: "album.zip", unzip: true, manifest: Manifest{}, want: StatusPresent},
+		{name: "folder without unzip", localName: "album.zip", manifest: Manifest{}, want: StatusNew},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := GetFileStatus(dir, test.localName, "roms", file, test.unzip, test.manifest)
+			if got != test.want {
+				t.Errorf("GetFileStatus() = %s, want %s", got, test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,196 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"handheldui/helpers/network"
+	"handheldui/output"
+	"html"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+// titleRetryDelay is how long a collection title that failed to download is not asked again
+const titleRetryDelay = 10 * time.Minute
+
+// ItemMetadata holds the descriptive fields of an archive.org item
+type ItemMetadata struct {
+	Identifier  string   `xml:"identifier" json:"identifier"`
+	Title       string   `xml:"title" json:"title"`
+	Description string   `xml:"description" json:"description"`
+	Date        string   `xml:"date" json:"date"`
+	Creator     []string `xml:"creator" json:"creator"`
+	LicenseURL  string   `xml:"licenseurl" json:"licenseurl"`
+	Rights      string   `xml:"rights" json:"rights"`
+}
+
+var (
+	reLineBreak = regexp.MustCompile(`(?i)<br\s*/?>|</p>|</div>|</li>`)
+	reTag       = regexp.MustCompile(`<[^>]*>`)
+
+	// Titles known in this session, the ones being fetched and the failed fetches
+	titles        = make(map[string]string)
+	titleFetches  = make(map[string]bool)
+	titleFailures = make(map[string]time.Time)
+	titlesLock    sync.Mutex
+)
+
+// DisplayTitle returns the title of the item, or its identifier when it has none
+func (m ItemMetadata) DisplayTitle() string {
+	if title := strings.TrimSpace(m.Title); title != "" {
+		return title
+	}
+	return m.Identifier
+}
+
+// License returns the license URL of the item, or its rights statement
+func (m ItemMetadata) License() string {
+	if m.LicenseURL != "" {
+		return m.LicenseURL
+	}
+	return strings.TrimSpace(m.Rights)
+}
+
+// PlainDescription returns the description without its HTML markup
+func (m ItemMetadata) PlainDescription() string {
+	text := reLineBreak.ReplaceAllString(m.Description, "\n")
+	text = html.UnescapeString(reTag.ReplaceAllString(text, ""))
+
+	var lines []string
+	for _, line := range strings.Split(text, "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			lines = append(lines, line)
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// Returns the cache file path of the item metadata of a collection
+func getItemCacheFilePath(name string) string {
+	return filepath.Join(".cache", "archive_metadata", fmt.Sprintf("meta_%s.json", name))
+}
+
+// cachedItemMetadata reads the cached _meta.xml fields of a collection
+func cachedItemMetadata(name string) (ItemMetadata, bool) {
+	cacheLock.RLock()
+	data, err := os.ReadFile(getItemCacheFilePath(name))
+	cacheLock.RUnlock()
+	if err != nil {
+		return ItemMetadata{}, false
+	}
+
+	var metadata ItemMetadata
+	if err := json.Unmarshal(data, &metadata); err != nil {
+		return ItemMetadata{}, false
+	}
+	return metadata, true
+}
+
+// FetchItemMetadata returns the _meta.xml fields of a collection, downloading them once
+func FetchItemMetadata(name string) (ItemMetadata, error) {
+	if metadata, ok := cachedItemMetadata(name); ok {
+		return metadata, nil
+	}
+
+	var lastErr error
+	for _, base := range orderedMirrors(collectionMirrors(name)) {
+		metadata, err := fetchItemMetadataFrom(base, name)
+		markMirror(base, err)
+		if err == nil {
+			titlesLock.Lock()
+			titles[name] = metadata.DisplayTitle()
+			delete(titleFailures, name)
+			titlesLock.Unlock()
+			return metadata, nil
+		}
+		if lastErr == nil || !IsAuthError(lastErr) {
+			lastErr = err
+		}
+	}
+
+	return ItemMetadata{Identifier: name}, lastErr
+}
+
+// CollectionTitle returns the title of a collection without waiting for the network. A
+// title not cached yet is fetched in the background, the identifier being returned until
+// it arrives. Failed fetches are retried after titleRetryDelay.
+func CollectionTitle(name string) string {
+	titlesLock.Lock()
+	defer titlesLock.Unlock()
+
+	if title, ok := titles[name]; ok {
+		return title
+	}
+	if titleFetches[name] || time.Since(titleFailures[name]) < titleRetryDelay {
+		return name
+	}
+
+	if metadata, ok := cachedItemMetadata(name); ok {
+		titles[name] = metadata.DisplayTitle()
+		return titles[name]
+	}
+
+	titleFetches[name] = true
+	go func() {
+		_, err := FetchItemMetadata(name)
+
+		titlesLock.Lock()
+		defer titlesLock.Unlock()
+		delete(titleFetches, name)
+		if err != nil {
+			output.Errorf("Error fetching title of %s: %v", name, err)
+			titleFailures[name] = time.Now()
+		}
+	}()
+
+	return name
+}
+
+// fetchItemMetadataFrom downloads the _meta.xml of a collection from a mirror and caches it
+func fetchItemMetadataFrom(base, name string) (ItemMetadata, error) {
+	link := mirrorFileURL(base, name, name+"_meta.xml")
+	resp, err := network.GetWithContext(withCollectionAuth(context.Background(), name), link)
+	if err != nil {
+		return ItemMetadata{}, output.Errorf("error fetching item metadata for %s: %v", name, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		err := &StatusError{URL: link, Status: resp.Status, Code: resp.StatusCode}
+		output.Errorf("%v", err)
+		return ItemMetadata{}, err
+	}
+
+	var metadata ItemMetadata
+	if err := xml.NewDecoder(resp.Body).Decode(&metadata); err != nil {
+		return ItemMetadata{}, output.Errorf("error decoding item metadata for %s: %v", name, err)
+	}
+	if metadata.Identifier == "" {
+		metadata.Identifier = name
+	}
+
+	data, err := json.Marshal(metadata)
+	if err != nil {
+		return ItemMetadata{}, output.Errorf("error encoding item metadata: %v", err)
+	}
+
+	cacheLock.Lock()
+	defer cacheLock.Unlock()
+
+	cacheFilePath := getItemCacheFilePath(name)
+	if err := os.MkdirAll(filepath.Dir(cacheFilePath), os.ModePerm); err != nil {
+		return ItemMetadata{}, output.Errorf("error creating cache directories: %v", err)
+	}
+	if err := os.WriteFile(cacheFilePath, data, 0644); err != nil {
+		return ItemMetadata{}, output.Errorf("error writing item metadata cache: %v", err)
+	}
+
+	return metadata, nil
+}
@@ -0,0 +1,252 @@
+package services
+
+import (
+	"handheldui/output"
+	"handheldui/vars"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// LargeListing is the number of listed files above which the files list reads the listing
+// page by page with a ListingSource
+const LargeListing = 20000
+
+// ListRepositoryFiles builds the items of every collection of a repository, with their
+// destination, local name and install status. Regions are filtered and disc sets are
+// grouped, as shown by the files list.
+func ListRepositoryFiles(repo vars.PlatformDetails, manifest Manifest) ([]map[string]interface{}, error) {
+	collections, err := RepositoryCollections(repo, false)
+	if err != nil {
+		return nil, output.Errorf("error listing collections of %s: %w", repo.Name, err)
+	}
+
+	return ListCollectionFiles(repo, collections, manifest, nil)
+}
+
+// ListCollectionFiles builds the items of some collections of a repository, as
+// ListRepositoryFiles does. The listed items, of the collections read before, are only
+// checked so that no two files are saved to the same local name.
+func ListCollectionFiles(repo vars.PlatformDetails, collections []vars.CollectionDetails, manifest Manifest, listed []map[string]interface{}) ([]map[string]interface{}, error) {
+	var items []map[string]interface{}
+
+	for _, collection := range collections {
+		index, err := FetchMetadata(collection.Name)
+		if err != nil {
+			return nil, output.Errorf("error fetching metadata of %s: %w", collection.Name, err)
+		}
+
+		err = index.Each(func(file File) error {
+			if isListed(repo, collection, file) {
+				items = append(items, newFileItem(repo, collection, file, manifest))
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, output.Errorf("error reading metadata of %s: %w", collection.Name, err)
+		}
+	}
+
+	// Keeps only the wanted regions and languages and, in 1G1R mode, the best variant of each game
+	items = FilterRegions(items, repo.Regions, repo.Languages, repo.OneGameOneRom)
+
+	// Files renamed to the same local name would overwrite each other
+	DisambiguateLocalNames(items, listed, manifest)
+
+	// Joins the tracks and discs of the same game into one entry
+	return GroupDiscSets(items, repo.MultiDisc), nil
+}
+
+// SplitMembers separates the members of parent and favorites entries, whose listings are
+// read when their folder is opened, from the collections listed right away.
+func SplitMembers(collections []vars.CollectionDetails) (listed, members []vars.CollectionDetails) {
+	for _, collection := range collections {
+		if collection.Parent != "" {
+			members = append(members, collection)
+		} else {
+			listed = append(listed, collection)
+		}
+	}
+	return listed, members
+}
+
+// isListed applies the extension list and the collection filters to a file
+func isListed(repo vars.PlatformDetails, collection vars.CollectionDetails, file File) bool {
+	// If extList is empty, add all files
+	if len(repo.ExtList) > 0 && !hasExtension(file.Name, repo.ExtList) {
+		return false
+	}
+	return IsFileIncluded(file, collection)
+}
+
+// newFileItem builds the list item of a file
+func newFileItem(repo vars.PlatformDetails, collection vars.CollectionDetails, file File, manifest Manifest) map[string]interface{} {
+	// Collection routes take precedence over the repository routes
+	destPath := ResolveDestination(file.Name, repo.Path, collection.Routes, repo.Routes)
+
+	// Files keep the folders they have in the collection, however they are downloaded
+	if dir := path.Dir(file.Name); dir != "." && !strings.HasPrefix(dir, "..") {
+		destPath = filepath.Join(destPath, filepath.FromSlash(dir))
+	}
+	localName := NormalizeFileName(file.Name, collection.Name, repo.Rename)
+
+	return map[string]interface{}{
+		"name":       listedName(collection, file),
+		"value":      file.URL,
+		"unzip":      collection.Unzip,
+		"collection": collection.Name,
+		"file":       file,
+		"path":       destPath,
+		"localname":  localName,
+		"status":     GetFileStatus(destPath, localName, collection.Name, file, collection.Unzip, manifest),
+	}
+}
+
+// listedName returns the name of a file in the list. Files of collections with a folder
+// are browsed inside it.
+func listedName(collection vars.CollectionDetails, file File) string {
+	if collection.Folder != "" {
+		return collection.Folder + "/" + file.Name
+	}
+	return file.Name
+}
+
+// listingEntry points to a listed file of a ListingSource
+type listingEntry struct {
+	collection int32
+	record     int32
+}
+
+// ListingSource reads the files of a repository page by page from the listing indexes,
+// keeping only the position of the listed files in memory. Regions are filtered while
+// scanning, but files are listed flat, without folders or disc sets.
+type ListingSource struct {
+	repo        vars.PlatformDetails
+	manifest    Manifest
+	collections []vars.CollectionDetails
+	indexes     []*FileIndex
+	entries     []listingEntry
+}
+
+// NewListingSource scans the indexes of the collections of a repository once, recording
+// the listed files.
+func NewListingSource(repo vars.PlatformDetails, collections []vars.CollectionDetails, manifest Manifest) (*ListingSource, error) {
+	source := &ListingSource{repo: repo, manifest: manifest, collections: collections}
+	filter := newRegionFilter(repo.Regions, repo.Languages, repo.OneGameOneRom)
+	for i, collection := range collections {
+		index, err := FetchMetadata(collection.Name)
+		if err != nil {
+			return nil, output.Errorf("error fetching metadata of %s: %w", collection.Name, err)
+		}
+		source.indexes = append(source.indexes, index)
+
+		record := int32(0)
+		err = index.Each(func(file File) error {
+			if isListed(repo, collection, file) {
+				entry := listingEntry{collection: int32(i), record: record}
+				slot, keep := filter.add(listedName(collection, file), len(source.entries))
+				switch {
+				case !keep:
+					// Unwanted region, or a worse variant in 1G1R mode
+				case slot == len(source.entries):
+					source.entries = append(source.entries, entry)
+				default:
+					source.entries[slot] = entry
+				}
+			}
+			record++
+			return nil
+		})
+		if err != nil {
+			return nil, output.Errorf("error reading metadata of %s: %w", collection.Name, err)
+		}
+	}
+
+	return source, nil
+}
+
+// Len returns how many files are listed
+func (s *ListingSource) Len() int {
+	return len(s.entries)
+}
+
+// Page builds the items from start to start+count, reading only their index pages.
+func (s *ListingSource) Page(start, count int) []map[string]interface{} {
+	if start < 0 {
+		start = 0
+	}
+	end := start + count
+	if end > len(s.entries) {
+		end = len(s.entries)
+	}
+
+	type pageKey struct{ collection, page int32 }
+	pages := make(map[pageKey][]File)
+
+	var items []map[string]interface{}
+	for _, entry := range s.entries[start:end] {
+		key := pageKey{entry.collection, entry.record / IndexPageSize}
+		files, ok := pages[key]
+		if !ok {
+			var err error
+			files, err = s.indexes[entry.collection].Page(int(key.page))
+			if err != nil {
+				output.Errorf("Error reading listing page: %v", err)
+			}
+			pages[key] = files
+		}
+
+		offset := int(entry.record % IndexPageSize)
+		if offset >= len(files) {
+			continue
+		}
+		items = append(items, newFileItem(s.repo, s.collections[entry.collection], files[offset], s.manifest))
+	}
+
+	return items
+}
+
+// Items builds every listed item in memory, for the listings small enough to be shown
+// with their local names disambiguated and their disc sets grouped.
+func (s *ListingSource) Items(listed []map[string]interface{}) []map[string]interface{} {
+	items := s.Page(0, s.Len())
+
+	// Files renamed to the same local name would overwrite each other
+	DisambiguateLocalNames(items, listed, s.manifest)
+
+	// Joins the tracks and discs of the same game into one entry
+	return GroupDiscSets(items, s.repo.MultiDisc)
+}
+
+// Find returns the item of a listed file by its full name, nil when not listed.
+func (s *ListingSource) Find(name string) map[string]interface{} {
+	for i, collection := range s.collections {
+		fileName := name
+		if collection.Folder != "" {
+			if !strings.HasPrefix(name, collection.Folder+"/") {
+				continue
+			}
+			fileName = strings.TrimPrefix(name, collection.Folder+"/")
+		}
+
+		file, ok, err := s.indexes[i].Find(fileName)
+		if err != nil {
+			output.Errorf("Error looking for %s: %v", name, err)
+			continue
+		}
+		if ok && isListed(s.repo, collection, file) {
+			return newFileItem(s.repo, collection, file, s.manifest)
+		}
+	}
+	return nil
+}
+
+// hasExtension checks if the file has one of the specified extensions
+func hasExtension(fileName string, extList []string) bool {
+	for _, ext := range extList {
+		if strings.HasSuffix(fileName, ext) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,144 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"handheldui/output"
+	"handheldui/vars"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// mirrorRetryDelay is how long a failing mirror is skipped, doubled on each new failure
+	mirrorRetryDelay    = 30 * time.Second
+	mirrorMaxRetryDelay = 10 * time.Minute
+)
+
+// StatusError is returned when a server answers a download with an unexpected status.
+type StatusError struct {
+	URL    string
+	Status string
+	Code   int
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("error downloading file from %s: %v", e.URL, e.Status)
+}
+
+type mirrorHealth struct {
+	failures int
+	retryAt  time.Time
+}
+
+var (
+	mirrorStates     = make(map[string]*mirrorHealth)
+	mirrorStateMutex sync.Mutex
+)
+
+// collectionMirrors returns the base URLs of a collection, in the configured order.
+// Collections listed by several repositories use the mirrors of the first one found.
+func collectionMirrors(name string) []string {
+	if collection, ok := findCollection(name); ok && len(collection.Mirrors) > 0 {
+		return collection.Mirrors
+	}
+	return []string{vars.Config().URLs.Archive}
+}
+
+// orderedMirrors puts the healthy mirrors first, keeping the configured order. Mirrors
+// waiting to be retried are still tried last, so a collection is never left without one.
+func orderedMirrors(bases []string) []string {
+	mirrorStateMutex.Lock()
+	defer mirrorStateMutex.Unlock()
+
+	var healthy, failing []string
+	now := time.Now()
+	for _, base := range bases {
+		if state, ok := mirrorStates[base]; ok && now.Before(state.retryAt) {
+			failing = append(failing, base)
+		} else {
+			healthy = append(healthy, base)
+		}
+	}
+
+	return append(healthy, failing...)
+}
+
+// markMirror records the result of a request to a mirror. Missing files do not count as
+// failures, since a mirror may only hold part of the collections.
+func markMirror(base string, err error) {
+	mirrorStateMutex.Lock()
+	defer mirrorStateMutex.Unlock()
+
+	var statusErr *StatusError
+	if err == nil || (errors.As(err, &statusErr) && statusErr.Code < 500) {
+		if err == nil {
+			delete(mirrorStates, base)
+		}
+		return
+	}
+
+	state, ok := mirrorStates[base]
+	if !ok {
+		state = &mirrorHealth{}
+		mirrorStates[base] = state
+	}
+
+	state.failures++
+	delay := mirrorRetryDelay << (state.failures - 1)
+	if delay > mirrorMaxRetryDelay || delay <= 0 {
+		delay = mirrorMaxRetryDelay
+	}
+	state.retryAt = time.Now().Add(delay)
+
+	output.Printf("Mirror %s failed %d times, skipping it for %v\n", base, state.failures, delay)
+}
+
+// mirrorFileURL returns the URL of a collection file on a mirror
+func mirrorFileURL(base, collection, fileName string) string {
+	segments := strings.Split(fileName, "/")
+	for i, segment := range segments {
+		segments[i] = url.PathEscape(segment)
+	}
+	return fmt.Sprintf("%s/%s/%s", strings.TrimSuffix(base, "/"), url.PathEscape(collection), strings.Join(segments, "/"))
+}
+
+// MirrorName returns the host of a mirror, as shown to the user
+func MirrorName(base string) string {
+	if parsed, err := url.Parse(base); err == nil && parsed.Host != "" {
+		return parsed.Host
+	}
+	return base
+}
+
+// downloadFromMirrors downloads a collection file from the first mirror that serves it.
+// The mirror callback receives the base URL of each mirror as it is tried.
+func downloadFromMirrors(ctx context.Context, collection, path, filename string, file File, progress func(int64, int64), mirror func(string)) error {
+	ctx = withCollectionAuth(ctx, collection)
+
+	var lastErr error
+	for _, base := range orderedMirrors(collectionMirrors(collection)) {
+		mirror(base)
+
+		err := DownloadFile(ctx, path, filename, mirrorFileURL(base, collection, file.Name), progress)
+		if ctx.Err() != nil {
+			return err
+		}
+
+		markMirror(base, err)
+		if err == nil {
+			return nil
+		}
+
+		output.Errorf("Mirror %s failed for %s: %v", MirrorName(base), file.Name, err)
+		// An authentication failure explains more than a later missing file
+		if lastErr == nil || !IsAuthError(lastErr) {
+			lastErr = err
+		}
+	}
+
+	return lastErr
+}
@@ -0,0 +1,156 @@
+package services
+
+import (
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"handheldui/helpers/patch"
+	"handheldui/output"
+	"handheldui/vars"
+	"hash/crc32"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Returns the folder where the patches of a collection are kept
+func getPatchCachePath(collection string) string {
+	return filepath.Join(".cache", "patches", collection)
+}
+
+// ApplyPatches applies the patches of a collection to the installed ROM files they
+// match. Each patched ROM is written as a new file and the original is kept. Patches
+// without a match pattern only apply to the ROMs they were made for, as told by the
+// checksums of UPS and BPS patches or by the expected source CRC32.
+func ApplyPatches(ctx context.Context, collection vars.CollectionDetails, installed InstalledFile, status func(string)) ([]string, error) {
+	romPaths := installed.Paths()
+
+	var patched []string
+	for _, patchDetails := range collection.Patches {
+		for _, romPath := range romPaths {
+			if patchDetails.Match != "" && !MatchPattern(patchDetails.Match, filepath.Base(romPath)) {
+				continue
+			}
+
+			status(fmt.Sprintf("Patching %s with %s", filepath.Base(romPath), patchDetails.File))
+
+			patchPath, err := fetchPatch(ctx, collection.Name, patchDetails)
+			if err != nil {
+				return patched, err
+			}
+
+			targetPath, err := applyPatch(romPath, patchPath, patchDetails)
+			if patchDetails.Match == "" && errors.Is(err, patch.ErrSourceMismatch) {
+				output.Printf("Patch %s does not apply to %s\n", patchDetails.File, filepath.Base(romPath))
+				continue
+			}
+			if err != nil {
+				return patched, output.Errorf("error applying %s to %s: %v", patchDetails.File, filepath.Base(romPath), err)
+			}
+			patched = append(patched, targetPath)
+		}
+	}
+
+	return patched, nil
+}
+
+// fetchPatch downloads the patch file from its collection, reusing the cached copy
+func fetchPatch(ctx context.Context, defaultCollection string, patchDetails vars.PatchDetails) (string, error) {
+	collection := patchDetails.Collection
+	if collection == "" {
+		collection = defaultCollection
+	}
+
+	index, err := FetchMetadata(collection)
+	if err != nil {
+		return "", err
+	}
+
+	file, ok, err := index.Find(patchDetails.File)
+	if err != nil {
+		return "", output.Errorf("error looking for patch %s: %v", patchDetails.File, err)
+	}
+	if !ok {
+		return "", output.Errorf("patch %s not found in %s", patchDetails.File, collection)
+	}
+
+	cachePath := getPatchCachePath(collection)
+	patchPath := filepath.Join(cachePath, filepath.Base(file.Name))
+	if info, err := os.Stat(patchPath); err == nil && (file.Size == 0 || info.Size() == file.Size) {
+		return patchPath, nil
+	}
+
+	if err := downloadFromMirrors(ctx, collection, cachePath, file.Name, file, func(int64, int64) {}, func(string) {}); err != nil {
+		return "", err
+	}
+
+	return patchPath, nil
+}
+
+// applyPatch writes the patched ROM next to the original, verifying its checksum
+func applyPatch(romPath, patchPath string, patchDetails vars.PatchDetails) (string, error) {
+	source, err := os.ReadFile(romPath)
+	if err != nil {
+		return "", err
+	}
+
+	patchData, err := os.ReadFile(patchPath)
+	if err != nil {
+		return "", err
+	}
+
+	// IPS patches apply to any file, so the ROM must be picked some other way
+	if patch.IsIPS(patchData) && patchDetails.Match == "" && patchDetails.SourceCRC32 == "" {
+		return "", fmt.Errorf("IPS patches need a match pattern or a sourcecrc32")
+	}
+
+	if patchDetails.SourceCRC32 != "" {
+		checksum := fmt.Sprintf("%08x", crc32.ChecksumIEEE(source))
+		if !strings.EqualFold(checksum, patchDetails.SourceCRC32) {
+			return "", fmt.Errorf("%w: expected crc32 %s, got %s", patch.ErrSourceMismatch, patchDetails.SourceCRC32, checksum)
+		}
+	}
+
+	target, err := patch.Apply(source, patchData)
+	if err != nil {
+		return "", err
+	}
+
+	if patchDetails.CRC32 != "" {
+		checksum := fmt.Sprintf("%08x", crc32.ChecksumIEEE(target))
+		if !strings.EqualFold(checksum, patchDetails.CRC32) {
+			return "", fmt.Errorf("crc32 mismatch: expected %s, got %s", patchDetails.CRC32, checksum)
+		}
+	}
+
+	if patchDetails.SHA1 != "" {
+		sum := sha1.Sum(target)
+		checksum := hex.EncodeToString(sum[:])
+		if !strings.EqualFold(checksum, patchDetails.SHA1) {
+			return "", fmt.Errorf("sha1 mismatch: expected %s, got %s", patchDetails.SHA1, checksum)
+		}
+	}
+
+	outputName := patchDetails.Output
+	if outputName == "" {
+		outputName = "{stem} (Patched){ext}"
+	}
+	targetPath := filepath.Join(filepath.Dir(romPath), expandPlaceholders(outputName, romPath, false))
+	if targetPath == romPath {
+		return "", fmt.Errorf("patched file would overwrite the original")
+	}
+
+	// Writes to a temporary file first, so a failure never leaves a partial ROM
+	tempPath := targetPath + ".tmp"
+	if err := os.WriteFile(tempPath, target, 0644); err != nil {
+		return "", err
+	}
+	if err := os.Rename(tempPath, targetPath); err != nil {
+		os.Remove(tempPath)
+		return "", err
+	}
+
+	return targetPath, nil
+}
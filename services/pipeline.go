@@ -0,0 +1,384 @@
+package services
+
+import (
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"handheldui/helpers/wrappers"
+	"handheldui/output"
+	"handheldui/vars"
+	"io"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// Pipeline step types
+const (
+	StepVerify  = "verify"
+	StepExtract = "extract"
+	StepRename  = "rename"
+	StepMove    = "move"
+	StepDelete  = "delete"
+	StepCommand = "command"
+)
+
+// DefaultPipeline returns the steps used when a repository has no pipeline configured.
+func DefaultPipeline(unzip bool) []vars.PipelineStep {
+	if !unzip {
+		return nil
+	}
+	return []vars.PipelineStep{
+		{Type: StepExtract},
+		{Type: StepDelete},
+	}
+}
+
+// pipelineRun keeps track of what a pipeline changed so it can be undone
+type pipelineRun struct {
+	installed     *InstalledFile
+	file          File
+	targets       []string
+	created       []string
+	createdDirs   []string
+	moved         [][2]string
+	backups       [][2]string
+	deleteArchive bool
+}
+
+// RunPipeline runs the post-download steps over an installed file. Each step reports its
+// name through status. When a step fails, every change made by the previous steps is
+// undone and the downloaded file is left as it was.
+func RunPipeline(ctx context.Context, steps []vars.PipelineStep, installed *InstalledFile, file File, status func(string)) error {
+	run := &pipelineRun{
+		installed: installed,
+		file:      file,
+		targets:   []string{installed.Path},
+	}
+
+	for _, step := range steps {
+		if ctx.Err() != nil {
+			run.rollback()
+			return output.Errorf("pipeline cancelled")
+		}
+
+		status(step.Type)
+
+		if err := run.runStep(step); err != nil {
+			output.Errorf("Pipeline step %s failed for %s: %v", step.Type, installed.Path, err)
+			run.rollback()
+			return output.Errorf("%s failed: %v", step.Type, err)
+		}
+	}
+
+	return run.commit()
+}
+
+func (r *pipelineRun) runStep(step vars.PipelineStep) error {
+	switch step.Type {
+	case StepVerify:
+		return r.verify()
+	case StepExtract:
+		return r.extract()
+	case StepRename:
+		return r.eachTarget(step, func(target string) (string, error) {
+			return r.rename(target, filepath.Join(filepath.Dir(target), expandPlaceholders(step.To, target, false)))
+		})
+	case StepMove:
+		return r.eachTarget(step, func(target string) (string, error) {
+			destDir := expandPlaceholders(step.To, target, false)
+			if !filepath.IsAbs(destDir) {
+				destDir = filepath.Join(filepath.Dir(target), destDir)
+			}
+			if _, err := os.Stat(destDir); os.IsNotExist(err) {
+				if err := os.MkdirAll(destDir, 0755); err != nil {
+					return "", err
+				}
+				r.createdDirs = append(r.createdDirs, destDir)
+			}
+			return r.rename(target, filepath.Join(destDir, filepath.Base(target)))
+		})
+	case StepDelete:
+		// The archive is only removed once every step succeeded
+		r.deleteArchive = true
+		return nil
+	case StepCommand:
+		return r.eachTarget(step, func(target string) (string, error) {
+			cmd := exec.Command("sh", "-c", expandPlaceholders(step.Command, target, true))
+			cmd.Dir = filepath.Dir(target)
+			out, err := cmd.CombinedOutput()
+			output.Printf("Pipeline command output: %s\n", out)
+			return target, err
+		})
+	default:
+		return output.Errorf("unknown pipeline step %s", step.Type)
+	}
+}
+
+// eachTarget applies an action to the current files matching the step pattern
+func (r *pipelineRun) eachTarget(step vars.PipelineStep, action func(string) (string, error)) error {
+	for index, target := range r.targets {
+		if step.Match != "" && !MatchPattern(step.Match, filepath.Base(target)) {
+			continue
+		}
+
+		newTarget, err := action(target)
+		if err != nil {
+			return err
+		}
+		r.targets[index] = newTarget
+	}
+	return nil
+}
+
+// verify compares the downloaded file with the size and checksums of its metadata. It
+// only checks the file as downloaded, before other steps extract or move it.
+func (r *pipelineRun) verify() error {
+	if len(r.created) > 0 || len(r.moved) > 0 {
+		return output.Errorf("verify must come before the steps changing the downloaded file")
+	}
+
+	info, err := os.Stat(r.installed.Path)
+	if err != nil {
+		return err
+	}
+
+	if r.file.Size > 0 && info.Size() != r.file.Size {
+		return output.Errorf("size mismatch: expected %d, got %d", r.file.Size, info.Size())
+	}
+
+	if r.file.MD5 != "" {
+		checksum, err := fileMD5(r.installed.Path)
+		if err != nil {
+			return err
+		}
+		if !strings.EqualFold(checksum, r.file.MD5) {
+			return output.Errorf("md5 mismatch: expected %s, got %s", r.file.MD5, checksum)
+		}
+	} else if r.file.SHA1 != "" {
+		checksum, err := fileSHA1(r.installed.Path)
+		if err != nil {
+			return err
+		}
+		if !strings.EqualFold(checksum, r.file.SHA1) {
+			return output.Errorf("sha1 mismatch: expected %s, got %s", r.file.SHA1, checksum)
+		}
+	}
+
+	return nil
+}
+
+// extract unzips the archive into a temporary folder and then merges its files into the
+// archive folder one by one. Existing folders are kept with their other files, and only
+// the files the archive replaces are backed up.
+func (r *pipelineRun) extract() error {
+	archive := r.installed.Path
+	if !strings.EqualFold(filepath.Ext(archive), ".zip") {
+		output.Printf("Skipping extraction of %s, not a zip file\n", archive)
+		return nil
+	}
+
+	destDir := filepath.Dir(archive)
+	tempDir, err := os.MkdirTemp(destDir, ".extract-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(tempDir)
+
+	if err := wrappers.UnzipFile(archive, tempDir); err != nil {
+		return err
+	}
+
+	var targets []string
+	err = filepath.WalkDir(tempDir, func(src string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		relative, err := filepath.Rel(tempDir, src)
+		if err != nil || relative == "." {
+			return err
+		}
+		target := filepath.Join(destDir, relative)
+
+		if entry.IsDir() {
+			return r.mkdir(target)
+		}
+
+		if err := r.replace(src, target); err != nil {
+			return err
+		}
+		r.created = append(r.created, target)
+		targets = append(targets, target)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	r.targets = targets
+
+	return nil
+}
+
+// mkdir creates a folder of the extracted tree unless it already exists
+func (r *pipelineRun) mkdir(dir string) error {
+	if info, err := os.Lstat(dir); err == nil {
+		if !info.IsDir() {
+			return output.Errorf("%s exists and is not a folder", dir)
+		}
+		return nil
+	}
+
+	if err := os.Mkdir(dir, 0755); err != nil {
+		return err
+	}
+	r.createdDirs = append(r.createdDirs, dir)
+	return nil
+}
+
+// rename moves a file, keeping what is needed to undo it
+func (r *pipelineRun) rename(from, to string) (string, error) {
+	if from == to {
+		return to, nil
+	}
+	if err := r.replace(from, to); err != nil {
+		return "", err
+	}
+	r.moved = append(r.moved, [2]string{from, to})
+	return to, nil
+}
+
+// replace moves the file src over dst, backing up dst when it already exists. Folders are
+// never replaced, so the files of the user inside them are left alone.
+func (r *pipelineRun) replace(src, dst string) error {
+	if info, err := os.Lstat(dst); err == nil {
+		if info.IsDir() {
+			return output.Errorf("%s is a folder and can't be replaced by a file", dst)
+		}
+
+		backup, err := backupName(dst)
+		if err != nil {
+			return err
+		}
+		if err := os.Rename(dst, backup); err != nil {
+			return err
+		}
+		r.backups = append(r.backups, [2]string{backup, dst})
+	}
+	return os.Rename(src, dst)
+}
+
+// backupName returns a free hidden name next to a file, so a backup never takes the place
+// of a real file
+func backupName(filePath string) (string, error) {
+	for i := 0; i < 1000; i++ {
+		backup := filepath.Join(filepath.Dir(filePath), fmt.Sprintf(".%s.pipeline-%d.bak", filepath.Base(filePath), i))
+		if _, err := os.Lstat(backup); os.IsNotExist(err) {
+			return backup, nil
+		}
+	}
+	return "", output.Errorf("no free backup name for %s", filePath)
+}
+
+// rollback undoes the changes in the reverse order they were made
+func (r *pipelineRun) rollback() {
+	for i := len(r.moved) - 1; i >= 0; i-- {
+		if err := os.Rename(r.moved[i][1], r.moved[i][0]); err != nil {
+			output.Errorf("error restoring %s: %v", r.moved[i][0], err)
+		}
+	}
+
+	for i := len(r.created) - 1; i >= 0; i-- {
+		if err := os.Remove(r.created[i]); err != nil {
+			output.Errorf("error removing %s: %v", r.created[i], err)
+		}
+	}
+
+	for i := len(r.backups) - 1; i >= 0; i-- {
+		if err := os.Rename(r.backups[i][0], r.backups[i][1]); err != nil {
+			output.Errorf("error restoring %s: %v", r.backups[i][1], err)
+		}
+	}
+
+	// Folders created by extract and move steps are removed only when left empty
+	for i := len(r.createdDirs) - 1; i >= 0; i-- {
+		os.Remove(r.createdDirs[i])
+	}
+}
+
+// commit removes the backups and the archive, and updates the installed record
+func (r *pipelineRun) commit() error {
+	for _, backup := range r.backups {
+		if err := os.Remove(backup[0]); err != nil {
+			output.Errorf("error removing backup %s: %v", backup[0], err)
+		}
+	}
+
+	archive := r.installed.Path
+	archiveKept := len(r.targets) == 1 && r.targets[0] == archive
+
+	if r.deleteArchive && !archiveKept {
+		if err := os.Remove(archive); err != nil && !os.IsNotExist(err) {
+			output.Errorf("error removing archive %s: %v", archive, err)
+		}
+	}
+
+	// The installed path follows the file when it was renamed or moved
+	if len(r.created) == 0 && len(r.targets) == 1 {
+		r.installed.Path = r.targets[0]
+		return nil
+	}
+
+	r.installed.Extracted = nil
+	for _, target := range r.targets {
+		relative, err := filepath.Rel(filepath.Dir(archive), target)
+		if err != nil {
+			relative = target
+		}
+		r.installed.Extracted = append(r.installed.Extracted, relative)
+	}
+
+	return nil
+}
+
+// expandPlaceholders replaces {file}, {dir}, {name}, {stem} and {ext} with the details of
+// the target path, quoting them for the shell when needed. The template is read once, so
+// placeholders inside the values are left as they are.
+func expandPlaceholders(template, target string, quote bool) string {
+	name := filepath.Base(target)
+	ext := filepath.Ext(name)
+
+	value := func(value string) string {
+		if quote {
+			return "'" + strings.ReplaceAll(value, "'", `'\''`) + "'"
+		}
+		return value
+	}
+
+	return strings.NewReplacer(
+		"{file}", value(target),
+		"{dir}", value(filepath.Dir(target)),
+		"{name}", value(name),
+		"{stem}", value(strings.TrimSuffix(name, ext)),
+		"{ext}", value(ext),
+	).Replace(template)
+}
+
+func fileSHA1(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	hash := sha1.New()
+	if _, err := io.Copy(hash, f); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
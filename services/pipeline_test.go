@@ -0,0 +1,90 @@
+package services
+
+import (
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"handheldui/vars"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestExpandPlaceholders(t *testing.T) {
+	tests := []struct {
+		name     string
+		template string
+		target   string
+		quote    bool
+		want     string
+	}{
+		{name: "every placeholder", template: "{dir}|{name}|{stem}|{ext}", target: filepath.Join("roms", "Game.zip"), want: "roms|Game.zip|Game|.zip"},
+		{name: "placeholders in the file name", template: "{stem} (Patched){ext}", target: "{ext}{stem}.zip", want: "{ext}{stem} (Patched).zip"},
+		{name: "quoted", template: "chmod +x {file}", target: "it's.sh", quote: true, want: `chmod +x 'it'\''s.sh'`},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := expandPlaceholders(test.template, test.target, test.quote); got != test.want {
+				t.Errorf("expandPlaceholders() = %q, want %q", got, test.want)
+			}
+		})
+	}
+}
+
+func TestRunPipelineVerify(t *testing.T) {
+	previous := vars.Config()
+	vars.SetConfig(&vars.ConfigDefinition{})
+	defer func() { vars.SetConfig(previous) }()
+
+	content := []byte("rom data")
+	sum := md5.Sum(content)
+	file := File{Name: "game.bin", Size: int64(len(content)), MD5: hex.EncodeToString(sum[:])}
+
+	tests := []struct {
+		name     string
+		steps    []vars.PipelineStep
+		content  []byte
+		wantErr  bool
+		wantPath string
+	}{
+		{
+			name:     "verify then rename",
+			steps:    []vars.PipelineStep{{Type: StepVerify}, {Type: StepRename, To: "{stem}.rom"}},
+			content:  content,
+			wantPath: "game.rom",
+		},
+		{
+			name:     "checksum mismatch",
+			steps:    []vars.PipelineStep{{Type: StepVerify}, {Type: StepRename, To: "{stem}.rom"}},
+			content:  []byte("bad data"),
+			wantErr:  true,
+			wantPath: "game.bin",
+		},
+		{
+			name:     "verify after rename",
+			steps:    []vars.PipelineStep{{Type: StepRename, To: "{stem}.rom"}, {Type: StepVerify}},
+			content:  content,
+			wantErr:  true,
+			wantPath: "game.bin",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			dir := t.TempDir()
+			installed := &InstalledFile{Name: "game.bin", Path: filepath.Join(dir, "game.bin")}
+			if err := os.WriteFile(installed.Path, test.content, 0644); err != nil {
+				t.Fatal(err)
+			}
+
+			err := RunPipeline(context.Background(), test.steps, installed, file, func(string) {})
+			if (err != nil) != test.wantErr {
+				t.Fatalf("RunPipeline() error = %v, want error %v", err, test.wantErr)
+			}
+			if _, err := os.Stat(filepath.Join(dir, test.wantPath)); err != nil {
+				t.Errorf("%s is missing after the pipeline: %v", test.wantPath, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,174 @@
+package services
+
+import (
+	"handheldui/vars"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+var (
+	bracketTagPattern = regexp.MustCompile(`\s*(\([^()]*\)|\[[^\[\]]*\])`)
+	// keptTagPattern matches the tags that tell the files of a set apart
+	keptTagPattern    = regexp.MustCompile(`(?i)^\s*\((?:disc|disk|cd|track|side)\s*[0-9a-z]+(?:\s+of\s+[0-9]+)?\)$`)
+	emptyTagPattern   = regexp.MustCompile(`\s*(\(\s*\)|\[\s*\])`)
+	whitespacePattern = regexp.MustCompile(`\s+`)
+	unsafeCharacters  = strings.NewReplacer(
+		"\\", "_", ":", "_", "*", "_", "?", "_", "\"", "_", "<", "_", ">", "_", "|", "_", "%", "_", "#", "_",
+	)
+)
+
+// cueTrackExtensions are never renamed, since cue sheets reference them by name
+var cueTrackExtensions = map[string]bool{
+	".cue": true, ".bin": true, ".img": true, ".sub": true, ".ccd": true, ".gdi": true, ".raw": true,
+}
+
+// asciiReplacements transliterates the characters that have a common ASCII spelling
+var asciiReplacements = map[rune]string{
+	'À': "A", 'Á': "A", 'Â': "A", 'Ã': "A", 'Ä': "A", 'Å': "A", 'Æ': "AE", 'Ç': "C",
+	'È': "E", 'É': "E", 'Ê': "E", 'Ë': "E", 'Ì': "I", 'Í': "I", 'Î': "I", 'Ï': "I",
+	'Ð': "D", 'Ñ': "N", 'Ò': "O", 'Ó': "O", 'Ô': "O", 'Õ': "O", 'Ö': "O", 'Ø': "O",
+	'Ù': "U", 'Ú': "U", 'Û': "U", 'Ü': "U", 'Ý': "Y", 'Þ': "Th", 'ß': "ss",
+	'à': "a", 'á': "a", 'â': "a", 'ã': "a", 'ä': "a", 'å': "a", 'æ': "ae", 'ç': "c",
+	'è': "e", 'é': "e", 'ê': "e", 'ë': "e", 'ì': "i", 'í': "i", 'î': "i", 'ï': "i",
+	'ð': "d", 'ñ': "n", 'ò': "o", 'ó': "o", 'ô': "o", 'õ': "o", 'ö': "o", 'ø': "o",
+	'ù': "u", 'ú': "u", 'û': "u", 'ü': "u", 'ý': "y", 'þ': "th", 'ÿ': "y",
+	'Œ': "OE", 'œ': "oe", 'Š': "S", 'š': "s", 'Ž': "Z", 'ž': "z", 'Ł': "L", 'ł': "l",
+	'‘': "'", '’': "'", '“': "\"", '”': "\"", '–': "-", '—': "-", '…': "...",
+}
+
+// NormalizeFileName returns the local name of a remote file after applying the rename
+// rules of a repository. Without rules, the base name is kept as is.
+func NormalizeFileName(fileName, collection string, rules *vars.RenameDetails) string {
+	baseName := path.Base(fileName)
+	if rules == nil || cueTrackExtensions[strings.ToLower(path.Ext(baseName))] {
+		return baseName
+	}
+
+	ext := path.Ext(baseName)
+	stem := strings.TrimSuffix(baseName, ext)
+	title := stem
+
+	if rules.StripTags {
+		title = bracketTagPattern.ReplaceAllStringFunc(title, func(tag string) string {
+			if keptTagPattern.MatchString(tag) {
+				return tag
+			}
+			return ""
+		})
+	}
+
+	if rules.Template != "" {
+		tags := ParseRomTags(baseName)
+		title = strings.NewReplacer(
+			"{title}", title,
+			"{name}", baseName,
+			"{stem}", stem,
+			"{ext}", strings.TrimPrefix(ext, "."),
+			"{region}", strings.Join(tags.Regions, ", "),
+			"{collection}", collection,
+		).Replace(rules.Template)
+		title = emptyTagPattern.ReplaceAllString(title, "")
+		ext = ""
+		if !strings.Contains(rules.Template, "{ext}") && !strings.Contains(rules.Template, "{name}") {
+			ext = path.Ext(baseName)
+		}
+	}
+
+	result := title + ext
+
+	if rules.ASCII {
+		result = transliterate(result)
+	}
+
+	if rules.Safe {
+		result = unsafeCharacters.Replace(result)
+	}
+
+	if rules.CollapseSpaces {
+		result = whitespacePattern.ReplaceAllString(result, " ")
+		if ext != "" {
+			result = strings.ReplaceAll(result, " "+ext, ext)
+		}
+		result = strings.TrimSpace(result)
+	}
+
+	// Never produces an empty or hidden name
+	if strings.TrimSuffix(result, ext) == "" || strings.HasPrefix(result, ".") {
+		return baseName
+	}
+
+	return strings.ReplaceAll(result, "/", "_")
+}
+
+// DisambiguateLocalNames finds the listed files that the rename rules would save to the
+// same path, like the region variants of a game, and gives them their remote name back.
+// When that is still not enough, as for the same name in two collections, the collection
+// is added to the name. The listed items, from collections read before, keep their names
+// but are taken into account.
+func DisambiguateLocalNames(items, listed []map[string]interface{}, manifest Manifest) {
+	destinationOf := func(item map[string]interface{}) string {
+		return strings.ToLower(filepath.Join(item["path"].(string), item["localname"].(string)))
+	}
+
+	for _, rename := range []func(map[string]interface{}) string{
+		func(item map[string]interface{}) string {
+			return path.Base(item["name"].(string))
+		},
+		func(item map[string]interface{}) string {
+			baseName := path.Base(item["name"].(string))
+			ext := path.Ext(baseName)
+			return strings.TrimSuffix(baseName, ext) + " (" + item["collection"].(string) + ")" + ext
+		},
+	} {
+		byDestination := make(map[string][]map[string]interface{})
+		for _, item := range items {
+			key := destinationOf(item)
+			byDestination[key] = append(byDestination[key], item)
+		}
+
+		taken := make(map[string]bool)
+		for _, item := range listed {
+			for _, file := range DiscSetFiles(item) {
+				taken[destinationOf(file)] = true
+			}
+		}
+
+		collisions := false
+		for key, sharing := range byDestination {
+			if len(sharing) < 2 && !taken[key] {
+				continue
+			}
+			collisions = true
+
+			for _, item := range sharing {
+				file := item["file"].(File)
+				item["localname"] = rename(item)
+				item["status"] = GetFileStatus(item["path"].(string), item["localname"].(string), item["collection"].(string), file, item["unzip"].(bool), manifest)
+			}
+		}
+
+		if !collisions {
+			return
+		}
+	}
+}
+
+func transliterate(text string) string {
+	var builder strings.Builder
+	for _, r := range text {
+		switch {
+		case r < unicode.MaxASCII:
+			builder.WriteRune(r)
+		case asciiReplacements[r] != "":
+			builder.WriteString(asciiReplacements[r])
+		case unicode.IsSpace(r):
+			builder.WriteRune(' ')
+		default:
+			builder.WriteRune('_')
+		}
+	}
+	return builder.String()
+}
@@ -0,0 +1,285 @@
+package services
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// RomTags holds the information parsed from No-Intro and Redump style file names,
+// like "Game Title (USA, Europe) (En,Fr,De) (Rev 1) [!].zip".
+type RomTags struct {
+	Title     string
+	Regions   []string
+	Languages []string
+	Revision  int
+	Disc      string
+	Track     string
+	Verified  bool
+	BadDump   bool
+	Flags     []string
+}
+
+var (
+	romTagPattern      = regexp.MustCompile(`\(([^()]*)\)|\[([^\[\]]*)\]`)
+	romRevisionPattern = regexp.MustCompile(`^(?i)(?:rev|v)\s*([0-9a-z.]+)$`)
+	romLanguagePattern = regexp.MustCompile(`^[A-Z][a-z](?:-[A-Z][a-z]+)?$`)
+	romDiscPattern     = regexp.MustCompile(`^(?i)(disc|disk|cd|track)\s*([0-9a-z]+)(?:\s+of\s+[0-9]+)?$`)
+)
+
+var romRegions = map[string]bool{
+	"World": true, "USA": true, "Europe": true, "Japan": true, "Asia": true,
+	"Australia": true, "Brazil": true, "Canada": true, "China": true, "France": true,
+	"Germany": true, "Hong Kong": true, "Italy": true, "Korea": true, "Netherlands": true,
+	"Russia": true, "Scandinavia": true, "Spain": true, "Sweden": true, "Taiwan": true,
+	"UK": true, "Unknown": true,
+}
+
+// romUnwantedFlags are the tags that make a variant a worse pick in 1G1R mode
+var romUnwantedFlags = map[string]bool{
+	"Beta": true, "Proto": true, "Demo": true, "Sample": true, "Pirate": true, "Unl": true,
+}
+
+// ParseRomTags extracts the tags of a file name.
+func ParseRomTags(fileName string) RomTags {
+	baseName := path.Base(fileName)
+	baseName = strings.TrimSuffix(baseName, path.Ext(baseName))
+
+	tags := RomTags{}
+
+	firstTag := romTagPattern.FindStringIndex(baseName)
+	if firstTag == nil {
+		tags.Title = strings.TrimSpace(baseName)
+		return tags
+	}
+	tags.Title = strings.TrimSpace(baseName[:firstTag[0]])
+
+	for _, match := range romTagPattern.FindAllStringSubmatch(baseName, -1) {
+		if match[2] != "" || strings.HasPrefix(match[0], "[") {
+			parseRomFlag(&tags, match[2])
+			continue
+		}
+		parseRomTag(&tags, match[1])
+	}
+
+	return tags
+}
+
+func parseRomTag(tags *RomTags, tag string) {
+	parts := splitRomTag(tag)
+
+	if allMatch(parts, func(part string) bool { return romRegions[part] }) {
+		tags.Regions = append(tags.Regions, parts...)
+		return
+	}
+
+	if allMatch(parts, romLanguagePattern.MatchString) {
+		tags.Languages = append(tags.Languages, parts...)
+		return
+	}
+
+	if match := romRevisionPattern.FindStringSubmatch(tag); match != nil {
+		tags.Revision = parseRevision(match[1])
+		return
+	}
+
+	if match := romDiscPattern.FindStringSubmatch(tag); match != nil {
+		if strings.EqualFold(match[1], "track") {
+			tags.Track = match[2]
+		} else {
+			tags.Disc = match[2]
+		}
+		return
+	}
+
+	if fields := strings.Fields(tag); len(fields) > 0 {
+		tags.Flags = append(tags.Flags, fields[0])
+	}
+}
+
+func parseRomFlag(tags *RomTags, flag string) {
+	switch {
+	case flag == "!":
+		tags.Verified = true
+	case strings.HasPrefix(flag, "b"):
+		tags.BadDump = true
+	default:
+		tags.Flags = append(tags.Flags, flag)
+	}
+}
+
+// parseRevision turns "1", "1.1" or "A" into a comparable number
+func parseRevision(revision string) int {
+	if number, err := strconv.ParseFloat(revision, 64); err == nil {
+		return int(number * 100)
+	}
+
+	revision = strings.ToUpper(revision)
+	if len(revision) == 1 && revision[0] >= 'A' && revision[0] <= 'Z' {
+		return int(revision[0]-'A'+1) * 100
+	}
+
+	return 0
+}
+
+func splitRomTag(tag string) []string {
+	var parts []string
+	for _, part := range strings.FieldsFunc(tag, func(r rune) bool { return r == ',' || r == '+' }) {
+		parts = append(parts, strings.TrimSpace(part))
+	}
+	return parts
+}
+
+func allMatch(parts []string, check func(string) bool) bool {
+	if len(parts) == 0 {
+		return false
+	}
+	for _, part := range parts {
+		if !check(part) {
+			return false
+		}
+	}
+	return true
+}
+
+// regionRank returns the position of the best region of the file in the priority
+// list, or -1 when none of its regions is wanted.
+func (t RomTags) regionRank(priority []string) int {
+	best := -1
+	for _, region := range t.Regions {
+		for rank, wanted := range priority {
+			if strings.EqualFold(region, wanted) && (best == -1 || rank < best) {
+				best = rank
+			}
+		}
+	}
+	return best
+}
+
+// languageRank returns the position of the best language of the file in the priority
+// list, or -1 when none of its languages is wanted. "En" matches "En-GB" too.
+func (t RomTags) languageRank(priority []string) int {
+	best := -1
+	for _, language := range t.Languages {
+		base, _, _ := strings.Cut(language, "-")
+		for rank, wanted := range priority {
+			if (strings.EqualFold(language, wanted) || strings.EqualFold(base, wanted)) && (best == -1 || rank < best) {
+				best = rank
+			}
+		}
+	}
+	return best
+}
+
+// score rates a variant in 1G1R mode, a higher score is a better pick. Unwanted
+// variants lose to any release, then the region priority decides, then the language
+// priority, which never outweighs one step of the regions.
+func (t RomTags) score(priority, languages []string) int {
+	score := 0
+
+	if rank := t.regionRank(priority); rank >= 0 {
+		score += (len(priority) - rank) * 100000
+	}
+	if rank := t.languageRank(languages); rank >= 0 {
+		score += 90000 * (len(languages) - rank) / len(languages)
+	}
+	for _, flag := range t.Flags {
+		if romUnwantedFlags[flag] {
+			score -= 100000000
+		}
+	}
+	if t.BadDump {
+		score -= 100000000
+	}
+	if t.Verified {
+		score += 1000
+	}
+
+	return score + t.Revision
+}
+
+// FilterRegions drops the items whose region tags are not in the priority list, or whose
+// language tags are not in the languages list. Files without these tags are always kept.
+// When oneGameOneRom is set, only the best variant of each title is kept.
+func FilterRegions(items []map[string]interface{}, priority, languages []string, oneGameOneRom bool) []map[string]interface{} {
+	if len(priority) == 0 && len(languages) == 0 && !oneGameOneRom {
+		return items
+	}
+
+	var filtered []map[string]interface{}
+	filter := newRegionFilter(priority, languages, oneGameOneRom)
+
+	for _, item := range items {
+		slot, keep := filter.add(item["name"].(string), len(filtered))
+		if !keep {
+			continue
+		}
+		if slot == len(filtered) {
+			filtered = append(filtered, item)
+		} else {
+			filtered[slot] = item
+		}
+	}
+
+	return filtered
+}
+
+// regionFilter applies the region filters to file names one at a time, remembering the
+// best variant of each title in 1G1R mode
+type regionFilter struct {
+	priority      []string
+	languages     []string
+	oneGameOneRom bool
+	bestVariants  map[string]regionVariant
+}
+
+type regionVariant struct {
+	slot  int
+	score int
+}
+
+func newRegionFilter(priority, languages []string, oneGameOneRom bool) *regionFilter {
+	return &regionFilter{
+		priority:      priority,
+		languages:     languages,
+		oneGameOneRom: oneGameOneRom,
+		bestVariants:  make(map[string]regionVariant),
+	}
+}
+
+// add checks a file, kept being the number of files kept so far. A kept file goes to
+// slot, which is kept when it is appended, or the slot of the worse variant it replaces.
+func (r *regionFilter) add(fileName string, kept int) (slot int, keep bool) {
+	if len(r.priority) == 0 && len(r.languages) == 0 && !r.oneGameOneRom {
+		return kept, true
+	}
+
+	tags := ParseRomTags(fileName)
+	if len(r.priority) > 0 && len(tags.Regions) > 0 && tags.regionRank(r.priority) < 0 {
+		return 0, false
+	}
+	if len(r.languages) > 0 && len(tags.Languages) > 0 && tags.languageRank(r.languages) < 0 {
+		return 0, false
+	}
+
+	if !r.oneGameOneRom {
+		return kept, true
+	}
+
+	// Variants are grouped by folder, title, disc, track and extension
+	key := strings.ToLower(fmt.Sprintf("%s/%s|%s|%s|%s", path.Dir(fileName), tags.Title, tags.Disc, tags.Track, path.Ext(fileName)))
+	score := tags.score(r.priority, r.languages)
+	best, found := r.bestVariants[key]
+	if !found {
+		r.bestVariants[key] = regionVariant{slot: kept, score: score}
+		return kept, true
+	}
+
+	if score > best.score {
+		r.bestVariants[key] = regionVariant{slot: best.slot, score: score}
+		return best.slot, true
+	}
+	return 0, false
+}
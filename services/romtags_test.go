@@ -0,0 +1,85 @@
+package services
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseRomTags(t *testing.T) {
+	got := ParseRomTags("Roms/Game Title (Europe) (En,Fr,De) (Rev 1) (Disc 2) [!].zip")
+	want := RomTags{
+		Title:     "Game Title",
+		Regions:   []string{"Europe"},
+		Languages: []string{"En", "Fr", "De"},
+		Revision:  100,
+		Disc:      "2",
+		Verified:  true,
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ParseRomTags() = %+v, want %+v", got, want)
+	}
+}
+
+func TestFilterRegions(t *testing.T) {
+	tests := []struct {
+		name          string
+		files         []string
+		regions       []string
+		languages     []string
+		oneGameOneRom bool
+		want          []string
+	}{
+		{
+			name:    "unwanted regions",
+			files:   []string{"Game (Japan).zip", "Game (USA).zip", "Homebrew.zip"},
+			regions: []string{"USA"},
+			want:    []string{"Game (USA).zip", "Homebrew.zip"},
+		},
+		{
+			name:      "unwanted languages",
+			files:     []string{"Game (Europe) (Fr,De).zip", "Game (Europe) (En-GB,Fr).zip", "Game (USA).zip"},
+			languages: []string{"En"},
+			want:      []string{"Game (Europe) (En-GB,Fr).zip", "Game (USA).zip"},
+		},
+		{
+			name:          "1g1r region priority",
+			files:         []string{"Game (Japan).zip", "Game (Europe).zip", "Game (USA) (Beta).zip", "Game (USA).zip"},
+			regions:       []string{"USA", "Europe"},
+			oneGameOneRom: true,
+			want:          []string{"Game (USA).zip"},
+		},
+		{
+			name:          "1g1r language priority",
+			files:         []string{"Game (Europe) (Fr,De).zip", "Game (Europe) (En,Fr,De).zip"},
+			regions:       []string{"Europe"},
+			languages:     []string{"En", "Fr"},
+			oneGameOneRom: true,
+			want:          []string{"Game (Europe) (En,Fr,De).zip"},
+		},
+		{
+			name:          "1g1r region before language",
+			files:         []string{"Game (Europe) (En).zip", "Game (USA) (Es).zip"},
+			regions:       []string{"USA", "Europe"},
+			languages:     []string{"En", "Es"},
+			oneGameOneRom: true,
+			want:          []string{"Game (USA) (Es).zip"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var items []map[string]interface{}
+			for _, file := range test.files {
+				items = append(items, map[string]interface{}{"name": file})
+			}
+
+			var got []string
+			for _, item := range FilterRegions(items, test.regions, test.languages, test.oneGameOneRom) {
+				got = append(got, item["name"].(string))
+			}
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("FilterRegions() = %v, want %v", got, test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,76 @@
+package services
+
+import (
+	"handheldui/output"
+	"handheldui/vars"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+var (
+	patternLock  sync.Mutex
+	patternCache = make(map[string]*regexp.Regexp)
+)
+
+// MatchPattern checks a file name against a pattern. Patterns prefixed with "re:" are
+// regular expressions matched against the full name, patterns starting with "." are
+// extensions and anything else is a glob matched against the base name.
+func MatchPattern(pattern, fileName string) bool {
+	switch {
+	case strings.HasPrefix(pattern, "re:"):
+		re := compilePattern(strings.TrimPrefix(pattern, "re:"))
+		return re != nil && re.MatchString(fileName)
+	case strings.HasPrefix(pattern, ".") && !strings.ContainsAny(pattern, "*?["):
+		return strings.HasSuffix(strings.ToLower(fileName), strings.ToLower(pattern))
+	default:
+		matched, err := path.Match(pattern, path.Base(fileName))
+		if err != nil {
+			output.Errorf("invalid glob pattern %s: %v", pattern, err)
+			return false
+		}
+		if !matched && strings.Contains(pattern, "/") {
+			matched, _ = path.Match(pattern, fileName)
+		}
+		return matched
+	}
+}
+
+// compilePattern compiles a regular expression only once
+func compilePattern(expr string) *regexp.Regexp {
+	patternLock.Lock()
+	defer patternLock.Unlock()
+
+	if re, ok := patternCache[expr]; ok {
+		return re
+	}
+
+	re, err := regexp.Compile(expr)
+	if err != nil {
+		output.Errorf("invalid regular expression %s: %v", expr, err)
+	}
+	patternCache[expr] = re
+
+	return re
+}
+
+// ResolveDestination returns the folder where a file will be saved. The route lists are
+// checked in order and the first matching rule wins; relative route paths are placed
+// inside the repository path.
+func ResolveDestination(fileName, repoPath string, routeLists ...[]vars.RouteDetails) string {
+	for _, routes := range routeLists {
+		for _, route := range routes {
+			if !MatchPattern(route.Match, fileName) {
+				continue
+			}
+			if filepath.IsAbs(route.Path) {
+				return route.Path
+			}
+			return filepath.Join(repoPath, route.Path)
+		}
+	}
+
+	return repoPath
+}
@@ -0,0 +1,249 @@
+package services
+
+import (
+	"handheldui/output"
+	"handheldui/vars"
+	"os"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// MaxSearchResults is how many results a search returns at most
+const MaxSearchResults = 100
+
+// searchEntry points to a file of a collection of the catalog
+type searchEntry struct {
+	collection int32
+	record     int32
+	// nameStart and nameEnd delimit the lower case name inside the catalog names
+	nameStart int32
+	nameEnd   int32
+}
+
+// searchCollection is a collection of a repository whose files are in the catalog
+type searchCollection struct {
+	repoKey  string
+	repo     vars.PlatformDetails
+	details  vars.CollectionDetails
+	index    *FileIndex
+	manifest Manifest
+}
+
+// SearchCatalog holds the lower case names of the listed files of every repository,
+// packed in a single buffer, so a search scans them without reading the indexes.
+type SearchCatalog struct {
+	names       []byte
+	entries     []searchEntry
+	collections []searchCollection
+	missing     []string
+}
+
+// SearchResult is a file matching a search
+type SearchResult struct {
+	RepoKey  string
+	RepoName string
+	Item     map[string]interface{}
+}
+
+// LoadSearchCatalog reads the cached listings of every configured repository, without
+// downloading anything. Collections whose listing or members were never downloaded, or
+// can't be read, are skipped and reported by Missing.
+func LoadSearchCatalog() *SearchCatalog {
+	catalog := &SearchCatalog{}
+
+	repoKeys := make([]string, 0, len(vars.Config().Repositories))
+	for repoKey := range vars.Config().Repositories {
+		repoKeys = append(repoKeys, repoKey)
+	}
+	sort.Strings(repoKeys)
+
+	for _, repoKey := range repoKeys {
+		repo := vars.Config().Repositories[repoKey]
+
+		collections, missing := CachedRepositoryCollections(repo)
+		catalog.missing = append(catalog.missing, missing...)
+
+		manifest, err := LoadManifest(repoKey)
+		if err != nil {
+			output.Errorf("Error loading installed files manifest: %v", err)
+			manifest = Manifest{}
+		}
+
+		for _, collection := range collections {
+			cacheLock.RLock()
+			index, err := OpenIndex(getIndexFilePath(collection.Name))
+			cacheLock.RUnlock()
+			if err != nil {
+				if !os.IsNotExist(err) {
+					output.Errorf("Error opening listing index of %s: %v", collection.Name, err)
+				}
+				catalog.missing = append(catalog.missing, collection.Name)
+				continue
+			}
+
+			collectionIndex := int32(len(catalog.collections))
+			catalog.collections = append(catalog.collections, searchCollection{
+				repoKey:  repoKey,
+				repo:     repo,
+				details:  collection,
+				index:    index,
+				manifest: manifest,
+			})
+
+			record := int32(0)
+			err = index.Each(func(file File) error {
+				if isListed(repo, collection, file) {
+					start := int32(len(catalog.names))
+					catalog.names = append(catalog.names, strings.ToLower(file.Name)...)
+					catalog.entries = append(catalog.entries, searchEntry{
+						collection: collectionIndex,
+						record:     record,
+						nameStart:  start,
+						nameEnd:    int32(len(catalog.names)),
+					})
+				}
+				record++
+				return nil
+			})
+			if err != nil {
+				output.Errorf("Error reading metadata of %s: %v", collection.Name, err)
+			}
+		}
+	}
+
+	return catalog
+}
+
+// Len returns how many files the catalog holds
+func (c *SearchCatalog) Len() int {
+	return len(c.entries)
+}
+
+// Missing returns the collections left out of the catalog, as their listing isn't cached
+func (c *SearchCatalog) Missing() []string {
+	return c.missing
+}
+
+// Search returns the best fuzzy matches of query, best first. Every word of the query
+// must appear in the file name with its letters in order.
+func (c *SearchCatalog) Search(query string) []SearchResult {
+	words := strings.Fields(strings.ToLower(query))
+	if len(words) == 0 {
+		return nil
+	}
+
+	type match struct {
+		entry int
+		score int
+	}
+	var best []match
+
+	for i, entry := range c.entries {
+		name := c.names[entry.nameStart:entry.nameEnd]
+
+		score, matched := 0, true
+		for _, word := range words {
+			wordScore, ok := fuzzyScore(name, word)
+			if !ok {
+				matched = false
+				break
+			}
+			score += wordScore
+		}
+		if !matched {
+			continue
+		}
+
+		// Keeps the best results sorted, dropping the worst once full
+		if len(best) == MaxSearchResults && score <= best[len(best)-1].score {
+			continue
+		}
+		position := sort.Search(len(best), func(j int) bool { return best[j].score < score })
+		if len(best) < MaxSearchResults {
+			best = append(best, match{})
+		}
+		copy(best[position+1:], best[position:])
+		best[position] = match{entry: i, score: score}
+	}
+
+	results := make([]SearchResult, 0, len(best))
+	for _, m := range best {
+		if result, ok := c.result(c.entries[m.entry]); ok {
+			results = append(results, result)
+		}
+	}
+	return results
+}
+
+// result reads the file of an entry from its index and builds its list item
+func (c *SearchCatalog) result(entry searchEntry) (SearchResult, bool) {
+	collection := c.collections[entry.collection]
+
+	files, err := collection.index.Page(int(entry.record / IndexPageSize))
+	if err != nil {
+		output.Errorf("Error reading listing page: %v", err)
+		return SearchResult{}, false
+	}
+	offset := int(entry.record % IndexPageSize)
+	if offset >= len(files) {
+		return SearchResult{}, false
+	}
+
+	return SearchResult{
+		RepoKey:  collection.repoKey,
+		RepoName: collection.repo.Name,
+		Item:     newFileItem(collection.repo, collection.details, files[offset], collection.manifest),
+	}, true
+}
+
+// fuzzyScore matches the letters of word in order inside name. Letters following each
+// other, letters starting a word and matches inside the base name score higher.
+func fuzzyScore(name []byte, word string) (int, bool) {
+	baseStart := 0
+	for i := len(name) - 1; i >= 0; i-- {
+		if name[i] == '/' {
+			baseStart = i + 1
+			break
+		}
+	}
+
+	score := 0
+	previous := -2
+	position := 0
+	for w := 0; w < len(word); w++ {
+		found := false
+		for ; position < len(name); position++ {
+			if name[position] != word[w] {
+				continue
+			}
+
+			score++
+			if position == previous+1 {
+				score += 3
+			}
+			if position == baseStart || (position > 0 && isNameSeparator(name[position-1])) {
+				score += 2
+			}
+			if position >= baseStart {
+				score++
+			}
+
+			previous = position
+			position++
+			found = true
+			break
+		}
+		if !found {
+			return 0, false
+		}
+	}
+
+	// Shorter names are closer to what was typed
+	return score*8 - len(name)/8, true
+}
+
+// isNameSeparator checks if a character separates the words of a file name
+func isNameSeparator(char byte) bool {
+	return char < 128 && !unicode.IsLetter(rune(char)) && !unicode.IsDigit(rune(char))
+}
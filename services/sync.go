@@ -0,0 +1,139 @@
+package services
+
+import (
+	"handheldui/output"
+	"handheldui/vars"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// SyncPlan lists what a sync changes in a repository
+type SyncPlan struct {
+	// Items are the new and changed files to download, disc sets included
+	Items []map[string]interface{}
+	// Removed are the installed files no longer listed by their collection
+	Removed []InstalledFile
+}
+
+// PlanSync downloads the listings of a repository again and compares them with the local
+// files. Installed files removed from their collection are only planned for deletion when
+// the repository has syncdelete enabled. Nothing is changed on disk.
+func PlanSync(repoKey string, repo vars.PlatformDetails) (SyncPlan, error) {
+	var plan SyncPlan
+
+	// Parent and favorites entries are searched again for new members
+	repoCollections, err := RepositoryCollections(repo, true)
+	if err != nil {
+		return plan, err
+	}
+
+	remoteNames := make(map[string]bool)
+	for _, collection := range repoCollections {
+		index, err := RefreshMetadata(collection.Name)
+		if err != nil {
+			return plan, err
+		}
+		err = index.Each(func(file File) error {
+			remoteNames[ManifestKey(collection.Name, file.Name)] = true
+			return nil
+		})
+		if err != nil {
+			return plan, err
+		}
+	}
+
+	manifest, err := LoadManifest(repoKey)
+	if err != nil {
+		return plan, err
+	}
+
+	items, err := ListRepositoryFiles(repo, manifest)
+	if err != nil {
+		return plan, err
+	}
+
+	for _, item := range items {
+		if status := item["status"].(string); status == StatusNew || status == StatusDifferent {
+			plan.Items = append(plan.Items, item)
+		}
+	}
+
+	sort.Slice(plan.Items, func(i, j int) bool {
+		return plan.Items[i]["name"].(string) < plan.Items[j]["name"].(string)
+	})
+
+	if repo.SyncDelete {
+		collections := make(map[string]bool)
+		for _, collection := range repoCollections {
+			collections[collection.Name] = true
+		}
+
+		// Only files of the repository collections are removed, never the ones of collections dropped from the config
+		for key, installed := range manifest {
+			if collections[installed.Collection] && !remoteNames[key] {
+				plan.Removed = append(plan.Removed, installed)
+			}
+		}
+
+		sort.Slice(plan.Removed, func(i, j int) bool {
+			return plan.Removed[i].Path < plan.Removed[j].Path
+		})
+	}
+
+	return plan, nil
+}
+
+// RemoveInstalledFiles deletes the files left by installs, patched copies included, and
+// drops them from the manifest. Only regular files are deleted; the folders holding them
+// are removed when they are left empty, up to the folder the install was saved in. It
+// returns how many installs were removed.
+func RemoveInstalledFiles(repoKey string, removed []InstalledFile) (int, error) {
+	count := 0
+	for _, installed := range removed {
+		baseDir := filepath.Dir(installed.Path)
+
+		paths := append(installed.Paths(), installed.Patched...)
+		for _, filePath := range paths {
+			info, err := os.Lstat(filePath)
+			if os.IsNotExist(err) {
+				continue
+			} else if err != nil {
+				return count, output.Errorf("error removing %s: %v", filePath, err)
+			}
+
+			// Older manifests listed extracted folders, which may hold other files
+			if info.IsDir() {
+				continue
+			}
+
+			if err := os.Remove(filePath); err != nil {
+				return count, output.Errorf("error removing %s: %v", filePath, err)
+			}
+			removeEmptyDirs(filepath.Dir(filePath), baseDir)
+		}
+
+		if err := RemoveInstall(repoKey, installed); err != nil {
+			return count, err
+		}
+		count++
+	}
+
+	return count, nil
+}
+
+// removeEmptyDirs removes dir and its parents while they are empty, stopping before baseDir
+func removeEmptyDirs(dir, baseDir string) {
+	for {
+		relative, err := filepath.Rel(baseDir, dir)
+		if err != nil || relative == "." || strings.HasPrefix(relative, "..") {
+			return
+		}
+		// Fails on folders that still hold files
+		if err := os.Remove(dir); err != nil {
+			return
+		}
+		dir = filepath.Dir(dir)
+	}
+}
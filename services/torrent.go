@@ -0,0 +1,98 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"handheldui/helpers/network"
+	"handheldui/helpers/torrent"
+	"handheldui/output"
+	"handheldui/vars"
+	"os"
+	"path/filepath"
+)
+
+// Transports a collection can download with
+const (
+	TransportHTTP    = "http"
+	TransportTorrent = "torrent"
+)
+
+// Returns the folder where the item torrents are kept
+func getTorrentCachePath() string {
+	return filepath.Join(".cache", "torrents")
+}
+
+// DownloadCollectionFile downloads a file with the transport selected by its collection,
+// trying its mirrors in order. The mirror callback receives the base URL of each mirror
+// as it is tried. When the torrent download fails, the file is downloaded over HTTP. Peers
+// can't be reached through a proxy, so torrents aren't used when there is one.
+func DownloadCollectionFile(ctx context.Context, collection vars.CollectionDetails, path, filename string, file File, progress func(int64, int64), mirror func(string)) error {
+	if collection.Transport == TransportTorrent && network.UsesProxy() {
+		output.Printf("Downloading %s over HTTP, torrent peers can't be reached through the proxy\n", file.Name)
+	} else if collection.Transport == TransportTorrent {
+		mirror(TransportTorrent)
+		err := DownloadTorrentFile(ctx, collection.Name, path, filename, file, progress)
+		if err == nil || ctx.Err() != nil {
+			return err
+		}
+		output.Errorf("Torrent download of %s failed, using HTTP: %v", file.Name, err)
+	}
+
+	return downloadFromMirrors(ctx, collection.Name, path, filename, file, progress, mirror)
+}
+
+// DownloadTorrentFile downloads a single file of an archive.org item through the item
+// torrent, using the HTTP URL of the file as web seed. The credentials of the collection
+// go with the torrent and web seed requests only, never to the trackers.
+func DownloadTorrentFile(ctx context.Context, collection, path, filename string, file File, progress func(int64, int64)) error {
+	metaCtx := withCollectionAuth(ctx, collection)
+	meta, err := fetchTorrent(metaCtx, collection, false)
+	if err != nil {
+		return err
+	}
+
+	// The cached torrent is outdated when the file changed since it was fetched
+	if entry, ok := meta.FindFile(file.Name); !ok || (file.Size > 0 && entry.Length != file.Size) {
+		meta, err = fetchTorrent(metaCtx, collection, true)
+		if err != nil {
+			return err
+		}
+	}
+
+	if err := os.MkdirAll(path, 0755); err != nil {
+		return output.Errorf("error creating directory %s: %v", path, err)
+	}
+
+	fullPath := filepath.Join(path, filepath.Base(filename))
+	if err := torrent.Download(ctx, meta, file.Name, fullPath, file.URL, collectionAuthHook(collection), progress); err != nil {
+		os.Remove(fullPath)
+		return output.Errorf("error downloading %s from torrent: %v", file.Name, err)
+	}
+
+	return nil
+}
+
+// fetchTorrent reads the torrent of an item, downloading it when missing or when refresh is set
+func fetchTorrent(ctx context.Context, collection string, refresh bool) (*torrent.MetaInfo, error) {
+	torrentName := collection + "_archive.torrent"
+	torrentPath := filepath.Join(getTorrentCachePath(), torrentName)
+
+	if _, err := os.Stat(torrentPath); refresh || err != nil {
+		link := fmt.Sprintf("%s/%s/%s", vars.Config().URLs.Archive, collection, torrentName)
+		if err := DownloadFile(ctx, getTorrentCachePath(), torrentName, link, func(int64, int64) {}); err != nil {
+			return nil, err
+		}
+	}
+
+	data, err := os.ReadFile(torrentPath)
+	if err != nil {
+		return nil, output.Errorf("error reading torrent %s: %v", torrentPath, err)
+	}
+
+	meta, err := torrent.ParseMetaInfo(data)
+	if err != nil {
+		return nil, output.Errorf("error parsing torrent %s: %v", torrentPath, err)
+	}
+
+	return meta, nil
+}
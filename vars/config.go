@@ -4,20 +4,124 @@ import (
 	_ "embed"
 	"encoding/json"
 	"fmt"
-	"log"
 	"math"
 )
 
+const (
+	// DefaultDatabaseURL is where the handheld database is read from
+	DefaultDatabaseURL = "https://handheld-database.github.io/handheld-database"
+	// DefaultArchiveURL is the download base of archive.org, used when a collection has no mirrors
+	DefaultArchiveURL = "https://archive.org/download"
+)
+
+// DefaultConfig replaces a config file that can't be used, and is the base of a config file
+// created by the settings. It is kept apart from the sample configs/config.json, which
+// users edit.
+//
+//go:embed defaults.json
+var DefaultConfig []byte
+
+type RouteDetails struct {
+	Match string `json:"match"`
+	Path  string `json:"path"`
+}
+
+type PipelineStep struct {
+	Type    string `json:"type"`
+	Match   string `json:"match"`
+	To      string `json:"to"`
+	Command string `json:"command"`
+}
+
+type RenameDetails struct {
+	StripTags      bool   `json:"striptags"`
+	ASCII          bool   `json:"ascii"`
+	Safe           bool   `json:"safe"`
+	CollapseSpaces bool   `json:"collapsespaces"`
+	Template       string `json:"template"`
+}
+
+type PatchDetails struct {
+	Match       string `json:"match"`
+	File        string `json:"file"`
+	Collection  string `json:"collection"`
+	Output      string `json:"output"`
+	SHA1        string `json:"sha1"`
+	CRC32       string `json:"crc32"`
+	SourceCRC32 string `json:"sourcecrc32"`
+}
+
 type CollectionDetails struct {
-	Name  string `json:"name"`
-	Unzip bool   `json:"unzip"`
+	Name            string         `json:"name"`
+	Type            string         `json:"type"`
+	Folder          string         `json:"folder"`
+	Parent          string         `json:"-"`
+	Unzip           bool           `json:"unzip"`
+	Transport       string         `json:"transport"`
+	Mirrors         []string       `json:"mirrors"`
+	Routes          []RouteDetails `json:"routes"`
+	Include         []string       `json:"include"`
+	Exclude         []string       `json:"exclude"`
+	HideDerivatives bool           `json:"hidederivatives"`
+	OriginalOnly    bool           `json:"originalonly"`
+	Patches         []PatchDetails `json:"patches"`
+}
+
+type ArtworkDetails struct {
+	Folder string `json:"folder"`
+	Name   string `json:"name"`
+	Width  int    `json:"width"`
+	Height int    `json:"height"`
+}
+
+type ExportDetails struct {
+	Type          string `json:"type"`
+	Path          string `json:"path"`
+	AfterDownload bool   `json:"afterdownload"`
 }
 
 type PlatformDetails struct {
-	Name        string              `json:"name"`
-	Path        string              `json:"path"`
-	ExtList     []string            `json:"extlist"`
-	Collections []CollectionDetails `json:"collections"`
+	Name          string              `json:"name"`
+	Path          string              `json:"path"`
+	System        string              `json:"system"`
+	ExtList       []string            `json:"extlist"`
+	HideInstalled bool                `json:"hideinstalled"`
+	SyncDelete    bool                `json:"syncdelete"`
+	Routes        []RouteDetails      `json:"routes"`
+	Regions       []string            `json:"regions"`
+	Languages     []string            `json:"languages"`
+	OneGameOneRom bool                `json:"1g1r"`
+	MultiDisc     bool                `json:"multidisc"`
+	Pipeline      []PipelineStep      `json:"pipeline"`
+	Rename        *RenameDetails      `json:"rename"`
+	Exports       []ExportDetails     `json:"exports"`
+	Collections   []CollectionDetails `json:"collections"`
+}
+
+type DownloadDetails struct {
+	Chunks            int     `json:"chunks"`
+	ChunkThreshold    int64   `json:"chunkthreshold"`
+	MaxRate           int64   `json:"maxrate"`
+	DownloadRate      int64   `json:"downloadrate"`
+	RequestsPerSecond float64 `json:"requestspersecond"`
+}
+
+type NetworkDetails struct {
+	Proxy    string              `json:"proxy"`
+	CABundle string              `json:"cabundle"`
+	Pins     map[string][]string `json:"pins"`
+}
+
+// AudioDetails set the volume, from 0 to 100, and whether the background music plays
+type AudioDetails struct {
+	Volume int  `json:"volume"`
+	Music  bool `json:"music"`
+}
+
+// URLDetails are the base URLs of the services the app reads from
+type URLDetails struct {
+	Database string `json:"database"`
+	Archive  string `json:"archive"`
 }
 
 type ScreenDetails struct {
@@ -31,29 +135,45 @@ type ScreenDetails struct {
 
 type ConfigDefinition struct {
 	Logs         bool                       `json:"logs"`
+	Platform     string                     `json:"platform"`
 	Control      map[string]string          `json:"control"`
 	Screen       ScreenDetails              `json:"screen"`
+	Download     DownloadDetails            `json:"download"`
+	Network      NetworkDetails             `json:"network"`
+	URLs         URLDetails                 `json:"urls"`
+	Audio        AudioDetails               `json:"audio"`
 	Repositories map[string]PlatformDetails `json:"repositories"`
+	Artwork      map[string]ArtworkDetails  `json:"artwork"`
 }
 
 func LoadConfig(configFile []byte) (*ConfigDefinition, error) {
-	var config ConfigDefinition
+	// The audio keys are optional, the decoding keeps these when they are missing
+	config := ConfigDefinition{Audio: AudioDetails{Volume: 100, Music: true}}
 	err := json.Unmarshal(configFile, &config)
 	if err != nil {
-		if config.Logs {
-			log.Fatalf("Erro ao fazer o parse do arquivo embutido: %v", err)
-		}
 		return nil, err
 	}
 
+	if config.URLs.Database == "" {
+		config.URLs.Database = DefaultDatabaseURL
+	}
+	if config.URLs.Archive == "" {
+		config.URLs.Archive = DefaultArchiveURL
+	}
+
+	config.UpdateScreen()
+
+	return &config, nil
+}
+
+// UpdateScreen calculates the layout of the configured screen size
+func (config *ConfigDefinition) UpdateScreen() {
 	aspectRation := calculateAspectRatio(config.Screen.Width, config.Screen.Height)
 	config.Screen.AspectRatio = aspectRation
 
 	config.Screen.MaxLines = calculateMaxLines(aspectRation)
 	config.Screen.MaxListItens = calculateMaxListItens(aspectRation)
 	config.Screen.MaxListItemWidth = calculateMaxListItemWidth(aspectRation)
-
-	return &config, nil
 }
 
 func calculateAspectRatio(width, height int32) string {
@@ -0,0 +1,125 @@
+package vars
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+)
+
+// overrideSetting is a setting that can be given on the command line or in the environment
+type overrideSetting struct {
+	flag  string
+	env   string
+	usage string
+	apply func(config *ConfigDefinition, value string) error
+}
+
+var overrideSettings = []overrideSetting{
+	{"logs", "HANDHELDB_LOGS", "enable the debug logs (true or false)", func(config *ConfigDefinition, value string) error {
+		logs, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("must be true or false")
+		}
+		config.Logs = logs
+		return nil
+	}},
+	{"width", "HANDHELDB_WIDTH", "screen width in pixels", func(config *ConfigDefinition, value string) error {
+		return parseScreenSize(value, &config.Screen.Width)
+	}},
+	{"height", "HANDHELDB_HEIGHT", "screen height in pixels", func(config *ConfigDefinition, value string) error {
+		return parseScreenSize(value, &config.Screen.Height)
+	}},
+	{"platform", "HANDHELDB_PLATFORM", "platform of the handheld database, like tsp", func(config *ConfigDefinition, value string) error {
+		config.Platform = value
+		return nil
+	}},
+	{"database-url", "HANDHELDB_DATABASE_URL", "base URL of the handheld database", func(config *ConfigDefinition, value string) error {
+		config.URLs.Database = value
+		return nil
+	}},
+	{"archive-url", "HANDHELDB_ARCHIVE_URL", "download base URL of the collections without mirrors", func(config *ConfigDefinition, value string) error {
+		config.URLs.Archive = value
+		return nil
+	}},
+}
+
+func parseScreenSize(value string, size *int32) error {
+	parsed, err := strconv.ParseInt(value, 10, 32)
+	if err != nil || parsed <= 0 {
+		return fmt.Errorf("must be a positive number")
+	}
+	*size = int32(parsed)
+	return nil
+}
+
+// Overrides are the settings given on the command line or in the environment. They are
+// applied over the config file, flags taking precedence over the environment.
+type Overrides struct {
+	ConfigPath string
+	values     map[string]string
+	sources    map[string]string
+}
+
+// ParseOverrides reads the command line arguments, without the program name, and the
+// environment. Invalid flags exit with the usage, like the flag package does.
+func ParseOverrides(args []string) *Overrides {
+	overrides := &Overrides{
+		ConfigPath: os.Getenv(ConfigEnvVar),
+		values:     make(map[string]string),
+		sources:    make(map[string]string),
+	}
+
+	for _, setting := range overrideSettings {
+		if value, ok := os.LookupEnv(setting.env); ok {
+			overrides.values[setting.flag] = value
+			overrides.sources[setting.flag] = setting.env
+		}
+	}
+
+	flags := flag.NewFlagSet("handheldb", flag.ExitOnError)
+	configPath := flags.String("config", "", "config file, overrides "+ConfigEnvVar)
+	for _, setting := range overrideSettings {
+		flags.String(setting.flag, "", fmt.Sprintf("%s, overrides %s", setting.usage, setting.env))
+	}
+	flags.Parse(args)
+
+	if *configPath != "" {
+		overrides.ConfigPath = *configPath
+	}
+	flags.Visit(func(f *flag.Flag) {
+		if f.Name != "config" {
+			overrides.values[f.Name] = f.Value.String()
+			overrides.sources[f.Name] = "-" + f.Name
+		}
+	})
+
+	return overrides
+}
+
+// Apply sets the overridden settings on a config. Invalid values are skipped and reported
+// as warnings.
+func (o *Overrides) Apply(config *ConfigDefinition) []ConfigIssue {
+	var issues []ConfigIssue
+	for _, setting := range overrideSettings {
+		value, ok := o.values[setting.flag]
+		if !ok {
+			continue
+		}
+
+		// Applies on a copy, so a bad value leaves the config untouched
+		updated := *config
+		if err := setting.apply(&updated, value); err != nil {
+			issues = append(issues, ConfigIssue{
+				Message: fmt.Sprintf("%s: %q is invalid, %v", o.sources[setting.flag], value, err),
+				Warning: true,
+			})
+			continue
+		}
+		*config = updated
+	}
+
+	config.UpdateScreen()
+
+	return issues
+}
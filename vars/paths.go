@@ -0,0 +1,62 @@
+package vars
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// ConfigEnvVar names the config file when the -config flag is not given
+const ConfigEnvVar = "HANDHELDB_CONFIG"
+
+// configFileName is the name of the config file inside the searched folders
+const configFileName = "config.json"
+
+// ConfigCandidates returns the places searched for the config file, in order: the folder
+// of the executable (beside launch.sh on the devices, or in its configs folder), the XDG
+// config folder and, for builds run from the source tree, the configs folder of the
+// working directory.
+func ConfigCandidates() []string {
+	var candidates []string
+
+	if executable, err := os.Executable(); err == nil {
+		if resolved, err := filepath.EvalSymlinks(executable); err == nil {
+			executable = resolved
+		}
+		appDir := filepath.Dir(executable)
+		candidates = append(candidates,
+			filepath.Join(appDir, configFileName),
+			filepath.Join(appDir, "configs", configFileName))
+	}
+
+	if configDir, err := os.UserConfigDir(); err == nil {
+		candidates = append(candidates, filepath.Join(configDir, "handheldb", configFileName))
+	}
+
+	return append(candidates, filepath.Join("configs", configFileName))
+}
+
+// FindConfigFile returns the config file to use. An explicit path, from the -config flag
+// or the HANDHELDB_CONFIG variable, is used as is. Otherwise the first candidate that
+// exists is used; when none does, the path of the first one is returned with an error
+// listing where the file was searched.
+func FindConfigFile(explicit string) (string, error) {
+	if explicit != "" {
+		return explicit, nil
+	}
+
+	candidates := ConfigCandidates()
+	for _, candidate := range candidates {
+		if info, err := os.Stat(candidate); err == nil && !info.IsDir() {
+			return candidate, nil
+		}
+	}
+
+	return candidates[0], fmt.Errorf("no config file found, searched %s", strings.Join(candidates, ", "))
+}
+
+// SecretsPath returns the secrets file kept beside a config file
+func SecretsPath(configPath string) string {
+	return filepath.Join(filepath.Dir(configPath), "secrets.json")
+}
@@ -0,0 +1,213 @@
+package vars
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// ConfigChange sets a value of the config file, its path being the keys leading to it,
+// like []string{"screen", "width"}
+type ConfigChange struct {
+	Path  []string
+	Value interface{}
+}
+
+// ErrInvalidConfig is returned when the changes would leave an invalid config file
+var ErrInvalidConfig = errors.New("the changed config is invalid")
+
+// SaveConfig writes changes to the config file, keeping its other keys, known or not, in
+// their order. The result is validated first and replaces the file atomically, so a
+// failed save leaves the previous file in place. defaults is used when the file doesn't
+// exist yet. The issues of the changed config are returned, with ErrInvalidConfig when
+// it has errors.
+func SaveConfig(configPath string, defaults []byte, changes []ConfigChange) ([]ConfigIssue, error) {
+	data, err := os.ReadFile(configPath)
+	mode := os.FileMode(0644)
+	if os.IsNotExist(err) {
+		data = defaults
+	} else if err != nil {
+		return nil, err
+	} else if info, err := os.Stat(configPath); err == nil {
+		mode = info.Mode().Perm()
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	root, err := decodeOrdered(decoder)
+	if err != nil {
+		return nil, fmt.Errorf("error reading %s: %v", configPath, err)
+	}
+	object, ok := root.(*jsonObject)
+	if !ok {
+		return nil, fmt.Errorf("error reading %s: the config is not an object", configPath)
+	}
+
+	for _, change := range changes {
+		if err := object.set(change.Path, change.Value); err != nil {
+			return nil, err
+		}
+	}
+
+	var buffer bytes.Buffer
+	encoder := json.NewEncoder(&buffer)
+	encoder.SetEscapeHTML(false)
+	encoder.SetIndent("", "    ")
+	if err := encoder.Encode(object); err != nil {
+		return nil, err
+	}
+
+	_, issues := ValidateConfig(buffer.Bytes())
+	if HasErrors(issues) {
+		return issues, ErrInvalidConfig
+	}
+
+	return issues, writeConfigFile(configPath, buffer.Bytes(), mode)
+}
+
+// writeConfigFile replaces the config file through a temporary file in the same folder
+func writeConfigFile(configPath string, data []byte, mode os.FileMode) error {
+	if err := os.MkdirAll(filepath.Dir(configPath), 0755); err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(configPath), ".config-*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), mode); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), configPath)
+}
+
+// jsonObject is a JSON object that keeps the order of its keys
+type jsonObject struct {
+	keys   []string
+	values map[string]interface{}
+}
+
+// decodeOrdered reads the next value, objects being read as *jsonObject
+func decodeOrdered(decoder *json.Decoder) (interface{}, error) {
+	token, err := decoder.Token()
+	if err != nil {
+		return nil, err
+	}
+
+	switch token {
+	case json.Delim('{'):
+		object := &jsonObject{values: make(map[string]interface{})}
+		for decoder.More() {
+			keyToken, err := decoder.Token()
+			if err != nil {
+				return nil, err
+			}
+			key := keyToken.(string)
+
+			value, err := decodeOrdered(decoder)
+			if err != nil {
+				return nil, err
+			}
+			if _, exists := object.values[key]; !exists {
+				object.keys = append(object.keys, key)
+			}
+			object.values[key] = value
+		}
+		_, err = decoder.Token()
+		return object, err
+	case json.Delim('['):
+		array := []interface{}{}
+		for decoder.More() {
+			value, err := decodeOrdered(decoder)
+			if err != nil {
+				return nil, err
+			}
+			array = append(array, value)
+		}
+		_, err = decoder.Token()
+		return array, err
+	}
+
+	return token, nil
+}
+
+// set changes the value at path, creating the missing objects on the way. Keys match
+// the existing ones regardless of case, as the config is decoded that way.
+func (o *jsonObject) set(path []string, value interface{}) error {
+	key := o.key(path[0])
+
+	if len(path) == 1 {
+		if _, exists := o.values[key]; !exists {
+			o.keys = append(o.keys, key)
+		}
+		o.values[key] = value
+		return nil
+	}
+
+	child, exists := o.values[key]
+	if !exists {
+		child = &jsonObject{values: make(map[string]interface{})}
+		o.keys = append(o.keys, key)
+		o.values[key] = child
+	}
+
+	object, ok := child.(*jsonObject)
+	if !ok {
+		return fmt.Errorf("%s is not an object in the config", key)
+	}
+	return object.set(path[1:], value)
+}
+
+// key returns the existing key matching name, or name when there is none
+func (o *jsonObject) key(name string) string {
+	if _, exists := o.values[name]; exists {
+		return name
+	}
+	for _, key := range o.keys {
+		if strings.EqualFold(key, name) {
+			return key
+		}
+	}
+	return name
+}
+
+func (o *jsonObject) MarshalJSON() ([]byte, error) {
+	var buffer bytes.Buffer
+	encoder := json.NewEncoder(&buffer)
+	encoder.SetEscapeHTML(false)
+
+	buffer.WriteByte('{')
+	for i, key := range o.keys {
+		if i > 0 {
+			buffer.WriteByte(',')
+		}
+		if err := encoder.Encode(key); err != nil {
+			return nil, err
+		}
+		buffer.WriteByte(':')
+		if err := encoder.Encode(o.values[key]); err != nil {
+			return nil, err
+		}
+	}
+	buffer.WriteByte('}')
+
+	return buffer.Bytes(), nil
+}
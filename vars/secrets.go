@@ -0,0 +1,63 @@
+package vars
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+)
+
+// SecretsFilePath is kept apart from config.json, so the config can be shared without
+// credentials. It is read from the folder of the config file in use.
+var SecretsFilePath = "configs/secrets.json"
+
+type CredentialDetails struct {
+	Type      string   `json:"type"`
+	Cookie    string   `json:"cookie"`
+	AccessKey string   `json:"accesskey"`
+	SecretKey string   `json:"secretkey"`
+	Username  string   `json:"username"`
+	Password  string   `json:"password"`
+	Token     string   `json:"token"`
+	Hosts     []string `json:"hosts"`
+	// Insecure allows sending the credential over plain http, to a LAN mirror without TLS
+	Insecure bool `json:"insecure"`
+}
+
+type SecretsDefinition struct {
+	Collections map[string]CredentialDetails `json:"collections"`
+}
+
+func LoadSecrets(secretsFile []byte) (*SecretsDefinition, error) {
+	var secrets SecretsDefinition
+	if err := json.Unmarshal(secretsFile, &secrets); err != nil {
+		return nil, err
+	}
+
+	return &secrets, nil
+}
+
+// ReadSecrets loads the secrets file. A missing file holds no credentials, and a file
+// that can't be read is reported as a warning, the app running without credentials.
+func ReadSecrets(secretsPath string) (*SecretsDefinition, []ConfigIssue) {
+	secretsFile, err := os.ReadFile(secretsPath)
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, []ConfigIssue{secretsIssue(secretsPath, err.Error())}
+	}
+
+	secrets, err := LoadSecrets(secretsFile)
+	if err != nil {
+		return nil, []ConfigIssue{secretsIssue(secretsPath, decodeIssue(secretsFile, err).String())}
+	}
+
+	return secrets, nil
+}
+
+// secretsIssue reports a problem of the secrets file among the config issues
+func secretsIssue(secretsPath, problem string) ConfigIssue {
+	return ConfigIssue{
+		Message: fmt.Sprintf("secrets file %s, %s; downloads run without credentials", secretsPath, problem),
+		Warning: true,
+	}
+}
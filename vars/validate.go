@@ -0,0 +1,328 @@
+package vars
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// ConfigIssue is a problem found in the config file. Warnings don't stop the config from
+// being used.
+type ConfigIssue struct {
+	Line    int
+	Column  int
+	Message string
+	Warning bool
+}
+
+func (i ConfigIssue) String() string {
+	if i.Line == 0 {
+		return i.Message
+	}
+	return fmt.Sprintf("line %d, column %d: %s", i.Line, i.Column, i.Message)
+}
+
+// HasErrors checks if any of the issues prevents the config from being used
+func HasErrors(issues []ConfigIssue) bool {
+	for _, issue := range issues {
+		if !issue.Warning {
+			return true
+		}
+	}
+	return false
+}
+
+// ValidateConfig parses a config file and checks it against the config definition. Syntax
+// and type errors, unknown keys, repositories without a path and malformed extensions are
+// reported with their position. The config is nil when it could not be parsed.
+func ValidateConfig(configFile []byte) (*ConfigDefinition, []ConfigIssue) {
+	config, err := LoadConfig(configFile)
+	if err != nil {
+		return nil, []ConfigIssue{decodeIssue(configFile, err)}
+	}
+
+	walker := &keyWalker{
+		data:    configFile,
+		decoder: json.NewDecoder(bytes.NewReader(configFile)),
+		offsets: make(map[string]int64),
+	}
+	if err := walker.value(reflect.TypeOf(ConfigDefinition{}), ""); err != nil {
+		return nil, []ConfigIssue{decodeIssue(configFile, err)}
+	}
+
+	issues := append(walker.issues, checkConfig(config, walker)...)
+	sort.SliceStable(issues, func(i, j int) bool {
+		if issues[i].Line != issues[j].Line {
+			return issues[i].Line < issues[j].Line
+		}
+		return issues[i].Column < issues[j].Column
+	})
+
+	return config, issues
+}
+
+// decodeIssue explains a parse error, with hints for the usual mistakes of hand edits
+func decodeIssue(data []byte, err error) ConfigIssue {
+	var syntaxErr *json.SyntaxError
+	var typeErr *json.UnmarshalTypeError
+
+	switch {
+	case errors.As(err, &syntaxErr):
+		offset := syntaxErr.Offset - 1
+		issue := issueAt(data, offset, syntaxErr.Error())
+		if offset >= 0 && offset < int64(len(data)) {
+			switch previous := lastSignificant(data, offset); {
+			case data[offset] == '/':
+				issue.Message += " (comments are not allowed)"
+			case (data[offset] == '}' || data[offset] == ']') && previous == ',':
+				issue.Message += " (remove the trailing comma)"
+			case data[offset] == '"' && previous != ',' && previous != '{' && previous != '[' && previous != ':':
+				issue.Message += " (missing comma)"
+			}
+		}
+		return issue
+	case errors.As(err, &typeErr):
+		return issueAt(data, typeErr.Offset, fmt.Sprintf("%s must be %s, not %s", typeErr.Field, typeErr.Type, typeErr.Value))
+	}
+
+	return ConfigIssue{Message: err.Error()}
+}
+
+// lastSignificant returns the last character before offset that is not a space
+func lastSignificant(data []byte, offset int64) byte {
+	for i := offset - 1; i >= 0; i-- {
+		if !isJSONSpace(data[i]) {
+			return data[i]
+		}
+	}
+	return 0
+}
+
+func isJSONSpace(char byte) bool {
+	return char == ' ' || char == '\t' || char == '\n' || char == '\r'
+}
+
+// issueAt creates an issue at the line and column of a byte offset
+func issueAt(data []byte, offset int64, message string) ConfigIssue {
+	if offset > int64(len(data)) {
+		offset = int64(len(data))
+	}
+	if offset < 0 {
+		offset = 0
+	}
+
+	line := bytes.Count(data[:offset], []byte("\n")) + 1
+	column := int(offset) - bytes.LastIndexByte(data[:offset], '\n')
+
+	return ConfigIssue{Line: line, Column: column, Message: message}
+}
+
+// keyWalker reads the config tokens, reporting the keys the definition doesn't have and
+// recording the offset of each value by its path, like "repositories.music.extlist[0]"
+type keyWalker struct {
+	data    []byte
+	decoder *json.Decoder
+	offsets map[string]int64
+	issues  []ConfigIssue
+}
+
+// start returns the offset of the next token, skipping separators
+func (w *keyWalker) start() int64 {
+	offset := w.decoder.InputOffset()
+	for offset < int64(len(w.data)) && (isJSONSpace(w.data[offset]) || w.data[offset] == ',' || w.data[offset] == ':') {
+		offset++
+	}
+	return offset
+}
+
+// value walks the next value, t being its type in the definition, nil when unknown
+func (w *keyWalker) value(t reflect.Type, path string) error {
+	for t != nil && t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	w.offsets[path] = w.start()
+	token, err := w.decoder.Token()
+	if err != nil {
+		return err
+	}
+
+	switch token {
+	case json.Delim('{'):
+		for w.decoder.More() {
+			keyOffset := w.start()
+			keyToken, err := w.decoder.Token()
+			if err != nil {
+				return err
+			}
+			key := keyToken.(string)
+
+			var child reflect.Type
+			if t != nil {
+				switch t.Kind() {
+				case reflect.Struct:
+					field, ok := jsonField(t, key)
+					if !ok {
+						location := path
+						if location == "" {
+							location = "the config"
+						}
+						issue := issueAt(w.data, keyOffset, fmt.Sprintf("unknown key %q in %s", key, location))
+						issue.Warning = true
+						w.issues = append(w.issues, issue)
+					}
+					child = field
+				case reflect.Map:
+					child = t.Elem()
+				}
+			}
+
+			childPath := key
+			if path != "" {
+				childPath = path + "." + key
+			}
+			if err := w.value(child, childPath); err != nil {
+				return err
+			}
+			w.offsets[childPath] = keyOffset
+		}
+		_, err = w.decoder.Token()
+	case json.Delim('['):
+		var elem reflect.Type
+		if t != nil && (t.Kind() == reflect.Slice || t.Kind() == reflect.Array) {
+			elem = t.Elem()
+		}
+		for index := 0; w.decoder.More(); index++ {
+			if err := w.value(elem, fmt.Sprintf("%s[%d]", path, index)); err != nil {
+				return err
+			}
+		}
+		_, err = w.decoder.Token()
+	}
+
+	return err
+}
+
+// jsonField finds the field decoded from a key, matching names like encoding/json does
+func jsonField(t reflect.Type, key string) (reflect.Type, bool) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+
+		name := field.Name
+		if tag := strings.Split(field.Tag.Get("json"), ",")[0]; tag == "-" {
+			continue
+		} else if tag != "" {
+			name = tag
+		}
+
+		if strings.EqualFold(name, key) {
+			return field.Type, true
+		}
+	}
+	return nil, false
+}
+
+// proxySchemes are the proxies the transport can use
+var proxySchemes = map[string]bool{"http": true, "https": true, "socks5": true, "socks5h": true}
+
+// checkConfig reports the values that parse but can't work
+func checkConfig(config *ConfigDefinition, walker *keyWalker) []ConfigIssue {
+	var issues []ConfigIssue
+	at := func(path, message string) {
+		issues = append(issues, issueAt(walker.data, walker.offsets[path], message))
+	}
+
+	if config.Screen.Width <= 0 || config.Screen.Height <= 0 {
+		at("screen", "screen width and height must be set")
+	}
+
+	if config.Audio.Volume < 0 || config.Audio.Volume > 100 {
+		at("audio.volume", "audio volume must be between 0 and 100")
+	}
+
+	if proxy := config.Network.Proxy; proxy != "" {
+		if proxyURL, err := url.Parse(proxy); err != nil || proxyURL.Host == "" {
+			at("network.proxy", fmt.Sprintf("bad proxy %q, proxies look like \"socks5://127.0.0.1:1080\"", proxy))
+		} else if !proxySchemes[proxyURL.Scheme] {
+			at("network.proxy", fmt.Sprintf("unsupported proxy scheme %q, use http, https, socks5 or socks5h", proxyURL.Scheme))
+		}
+	}
+
+	if bundle := config.Network.CABundle; bundle != "" {
+		if data, err := os.ReadFile(bundle); err != nil {
+			at("network.cabundle", fmt.Sprintf("CA bundle %s can't be read: %v", bundle, err))
+		} else if !x509.NewCertPool().AppendCertsFromPEM(data) {
+			at("network.cabundle", fmt.Sprintf("no certificates found in CA bundle %s", bundle))
+		}
+	}
+
+	for host, pins := range config.Network.Pins {
+		for index, pin := range pins {
+			sum, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(pin, "sha256/"))
+			if err != nil || len(sum) != sha256.Size {
+				at(fmt.Sprintf("network.pins.%s[%d]", host, index), fmt.Sprintf("bad pin %q for %s, pins are base64 SHA-256 hashes of a public key", pin, host))
+			}
+		}
+	}
+
+	for key, repo := range config.Repositories {
+		repoPath := "repositories." + key
+		if strings.TrimSpace(repo.Path) == "" {
+			at(repoPath, fmt.Sprintf("repository %s has no path", key))
+		}
+
+		for index, ext := range repo.ExtList {
+			if !strings.HasPrefix(ext, ".") || len(ext) < 2 || strings.ContainsAny(ext, "*?/\\ ") {
+				at(fmt.Sprintf("%s.extlist[%d]", repoPath, index), fmt.Sprintf("bad extension %q in repository %s, extensions look like \".zip\"", ext, key))
+			}
+		}
+
+		for index, step := range repo.Pipeline {
+			stepPath := fmt.Sprintf("%s.pipeline[%d]", repoPath, index)
+			switch step.Type {
+			case "verify":
+				if index > 0 {
+					at(stepPath, fmt.Sprintf("verify must be the first pipeline step of repository %s, it checks the file as downloaded", key))
+				}
+			case "extract", "delete":
+			case "rename", "move":
+				if strings.TrimSpace(step.To) == "" {
+					at(stepPath, fmt.Sprintf("%s step %d of repository %s needs a \"to\"", step.Type, index+1, key))
+				}
+			case "command":
+				if strings.TrimSpace(step.Command) == "" {
+					at(stepPath, fmt.Sprintf("command step %d of repository %s needs a \"command\"", index+1, key))
+				}
+			default:
+				at(stepPath, fmt.Sprintf("unknown pipeline step %q in repository %s, steps are verify, extract, rename, move, delete and command", step.Type, key))
+			}
+		}
+
+		for index, collection := range repo.Collections {
+			if strings.TrimSpace(collection.Name) == "" {
+				at(fmt.Sprintf("%s.collections[%d]", repoPath, index), fmt.Sprintf("collection %d of repository %s has no name", index+1, key))
+			}
+
+			for patchIndex, patch := range collection.Patches {
+				if strings.EqualFold(filepath.Ext(patch.File), ".ips") && patch.Match == "" && patch.SourceCRC32 == "" {
+					at(fmt.Sprintf("%s.collections[%d].patches[%d]", repoPath, index, patchIndex), fmt.Sprintf("IPS patch %s needs a match or a sourcecrc32, it would apply to every file", patch.File))
+				}
+			}
+		}
+	}
+
+	return issues
+}
@@ -0,0 +1,87 @@
+package vars
+
+import (
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestDefaultConfig(t *testing.T) {
+	config, issues := ValidateConfig(DefaultConfig)
+	if config == nil || len(issues) > 0 {
+		t.Fatalf("ValidateConfig(DefaultConfig) = %v, want no issues", issues)
+	}
+}
+
+func TestValidateNetwork(t *testing.T) {
+	dir := t.TempDir()
+	notPEM := filepath.Join(dir, "notpem.crt")
+	if err := os.WriteFile(notPEM, pem.EncodeToMemory(&pem.Block{Type: "NOTHING", Bytes: []byte("x")}), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		network string
+		want    string
+	}{
+		{name: "socks proxy", network: `{"proxy": "socks5://127.0.0.1:1080"}`},
+		{name: "proxy scheme", network: `{"proxy": "ftp://127.0.0.1:21"}`, want: `unsupported proxy scheme "ftp"`},
+		{name: "proxy without host", network: `{"proxy": "127.0.0.1"}`, want: `bad proxy "127.0.0.1"`},
+		{name: "missing bundle", network: `{"cabundle": "` + filepath.ToSlash(filepath.Join(dir, "missing.pem")) + `"}`, want: "can't be read"},
+		{name: "bundle without certificates", network: `{"cabundle": "` + filepath.ToSlash(notPEM) + `"}`, want: "no certificates found"},
+		{name: "pin", network: `{"pins": {"archive.org": ["sha256/47DEQpj8HBSa+/TImW+5JCeuQeRkm5NMpJWZG3hSuFU="]}}`},
+		{name: "malformed pin", network: `{"pins": {"archive.org": ["sha256/short"]}}`, want: `bad pin "sha256/short" for archive.org`},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			configFile := `{"screen": {"width": 640, "height": 480}, "network": ` + test.network + `}`
+			_, issues := ValidateConfig([]byte(configFile))
+
+			if test.want == "" {
+				if len(issues) > 0 {
+					t.Errorf("ValidateConfig() = %v, want no issues", issues)
+				}
+				return
+			}
+			if len(issues) != 1 || !strings.Contains(issues[0].Message, test.want) || issues[0].Line != 1 {
+				t.Errorf("ValidateConfig() = %v, want an issue on line 1 containing %q", issues, test.want)
+			}
+		})
+	}
+}
+
+func TestValidatePipeline(t *testing.T) {
+	tests := []struct {
+		name     string
+		pipeline string
+		want     string
+	}{
+		{name: "valid steps", pipeline: `[{"type": "verify"}, {"type": "extract"}, {"type": "rename", "to": "{stem}.bin"}, {"type": "move", "to": "Imgs"}, {"type": "command", "command": "true"}, {"type": "delete"}]`},
+		{name: "verify after rename", pipeline: `[{"type": "rename", "to": "{stem}.bin"}, {"type": "verify"}]`, want: "verify must be the first pipeline step"},
+		{name: "unknown step", pipeline: `[{"type": "unzip"}]`, want: `unknown pipeline step "unzip"`},
+		{name: "rename without to", pipeline: `[{"type": "rename"}]`, want: `rename step 1 of repository roms needs a "to"`},
+		{name: "move without to", pipeline: `[{"type": "extract"}, {"type": "move", "to": " "}]`, want: `move step 2 of repository roms needs a "to"`},
+		{name: "command without command", pipeline: `[{"type": "command"}]`, want: `command step 1 of repository roms needs a "command"`},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			configFile := "{\"screen\": {\"width\": 640, \"height\": 480},\n\"repositories\": {\"roms\": {\"path\": \"/roms\",\n\"pipeline\": " + test.pipeline + "}}}"
+			_, issues := ValidateConfig([]byte(configFile))
+
+			if test.want == "" {
+				if len(issues) > 0 {
+					t.Errorf("ValidateConfig() = %v, want no issues", issues)
+				}
+				return
+			}
+			if len(issues) != 1 || !strings.Contains(issues[0].Message, test.want) || issues[0].Line != 3 {
+				t.Errorf("ValidateConfig() = %v, want an issue on line 3 containing %q", issues, test.want)
+			}
+		})
+	}
+}
@@ -1,6 +1,8 @@
 package vars
 
 import (
+	"sync/atomic"
+
 	"github.com/veandco/go-sdl2/sdl"
 	"github.com/veandco/go-sdl2/ttf"
 )
@@ -13,23 +15,54 @@ type FontColors struct {
 }
 
 var (
-	CurrentPlatform string
-	CurrentScreen   string
-	CurrentSystem   string
-	CurrentGame     string
-	CurrentRepo     string
-	CurrentTester   string
-	BodyFont        *ttf.Font
-	HeaderFont      *ttf.Font
-	BodyBigFont     *ttf.Font
-	LongTextFont    *ttf.Font
-	Colors          FontColors
-	Config          *ConfigDefinition
+	CurrentScreen string
+	CurrentSystem string
+	CurrentGame   string
+	CurrentRepo   string
+	CurrentTester string
+	BodyFont      *ttf.Font
+	HeaderFont    *ttf.Font
+	BodyBigFont   *ttf.Font
+	LongTextFont  *ttf.Font
+	Colors        FontColors
+	Secrets       *SecretsDefinition
+)
+
+// The config and the platform are replaced by the settings screen while downloads, exports
+// and the search read them from their own goroutines, so they are published atomically.
+var (
+	config          atomic.Pointer[ConfigDefinition]
+	currentPlatform atomic.Pointer[string]
 )
 
+// Config returns the config in use. It is never changed in place: a modified copy is
+// published with SetConfig instead.
+func Config() *ConfigDefinition {
+	return config.Load()
+}
+
+// SetConfig replaces the config in use
+func SetConfig(newConfig *ConfigDefinition) {
+	config.Store(newConfig)
+}
+
+// CurrentPlatform returns the platform the systems and games are listed for
+func CurrentPlatform() string {
+	if platform := currentPlatform.Load(); platform != nil {
+		return *platform
+	}
+	return ""
+}
+
+// SetCurrentPlatform replaces the platform the systems and games are listed for
+func SetCurrentPlatform(platform string) {
+	currentPlatform.Store(&platform)
+}
+
 func InitVars() {
-	Config = nil
-	CurrentPlatform = "tsp"
+	SetConfig(nil)
+	Secrets = &SecretsDefinition{}
+	SetCurrentPlatform("tsp")
 	CurrentScreen = "home_screen"
 	CurrentSystem = ""
 	CurrentGame = ""